@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// crossOrgAdmins takes the data collected for multiple orgs and returns the users who
+// are an admin of at least one repo in more than one of those orgs, sorted by login
+func crossOrgAdmins(orgs []ghOrgData) []ghCrossOrgAdmin {
+	// Gather every org and repo each admin shows up in
+	admins := make(map[string]*ghCrossOrgAdmin)
+	for _, o := range orgs {
+		for _, r := range o.Repos {
+			for _, a := range o.Admins[r.Name] {
+				key := strings.ToLower(a.Login)
+				c, exists := admins[key]
+				if !exists {
+					c = &ghCrossOrgAdmin{
+						Login:       a.Login,
+						Name:        o.Names[a.Login].Name,
+						Email:       o.Names[a.Login].Email,
+						EmailSource: o.Names[a.Login].EmailSource,
+					}
+					admins[key] = c
+				}
+				if !containsString(c.Orgs, o.Org) {
+					c.Orgs = append(c.Orgs, o.Org)
+				}
+				c.Repos = append(c.Repos, r.FullName)
+			}
+		}
+	}
+
+	// Keep only those who are admins in more than one org
+	var cross []ghCrossOrgAdmin
+	for _, v := range admins {
+		if len(v.Orgs) > 1 {
+			cross = append(cross, *v)
+		}
+	}
+	sort.Slice(cross, func(i, j int) bool {
+		return strings.ToLower(cross[i].Login) < strings.ToLower(cross[j].Login)
+	})
+
+	return cross
+}
+
+// containsString returns true if the string s is in the slice l
+func containsString(l []string, s string) bool {
+	for _, v := range l {
+		if strings.Compare(v, s) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reasons a repo is orphaned
+const (
+	orphanNoAdmins       = "no-admins"
+	orphanInactiveAdmins = "inactive-admins"
+	orphanOwnersOnly     = "owners-only"
+)
+
+// orphanedRepos takes the data collected for one or more orgs and returns the repos without an
+// active admin who isn't an org owner. Bots are ignored, so a repo only administered by bots has
+// no admins. Admins who are suspended or whose account has been deleted are inactive
+func orphanedRepos(orgs []ghOrgData) []ghOrphanedRepo {
+	var orphans []ghOrphanedRepo
+	for _, o := range orgs {
+		for _, r := range o.Repos {
+			var humans, active []string
+			for _, a := range o.Admins[r.Name] {
+				if isBot(a.Login, a.Type) {
+					continue
+				}
+				humans = append(humans, a.Login)
+				if !o.Names[a.Login].Suspended && !o.Names[a.Login].Departed {
+					active = append(active, a.Login)
+				}
+			}
+
+			reason := ""
+			switch {
+			case len(humans) == 0:
+				reason = orphanNoAdmins
+			case len(active) == 0:
+				reason = orphanInactiveAdmins
+			default:
+				reason = orphanOwnersOnly
+				for _, l := range active {
+					if !o.Owners[strings.ToLower(l)] {
+						reason = ""
+						break
+					}
+				}
+			}
+			if len(reason) > 0 {
+				orphans = append(orphans, ghOrphanedRepo{
+					Org:    o.Org,
+					Repo:   r.Name,
+					Reason: reason,
+					Admins: humans,
+				})
+			}
+		}
+	}
+
+	return orphans
+}
+
+// isBot returns true if a Github account with login l and type t is a bot or app
+func isBot(l string, t string) bool {
+	return strings.EqualFold(t, "Bot") || strings.HasSuffix(strings.ToLower(l), "[bot]")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCrossOrgAdmins(t *testing.T) {
+	acme := ghOrgData{
+		Org:   "acme",
+		Repos: ghRepoInfo{{Name: "api", FullName: "acme/api"}, {Name: "web", FullName: "acme/web"}},
+		Admins: map[string]ghCollaborators{
+			"api": {{Login: "jdoe"}, {Login: "asmith"}},
+			"web": {{Login: "jdoe"}},
+		},
+		Names: map[string]ghNameDetail{"jdoe": {Name: "Jane Doe", Email: "jane@example.com"}},
+	}
+	labs := ghOrgData{
+		Org:    "acme-labs",
+		Repos:  ghRepoInfo{{Name: "lab", FullName: "acme-labs/lab"}},
+		Admins: map[string]ghCollaborators{"lab": {{Login: "JDoe"}, {Login: "carol"}}},
+	}
+
+	// jdoe is matched across orgs ignoring case, asmith and carol are only in one org
+	got := crossOrgAdmins([]ghOrgData{acme, labs})
+	want := []ghCrossOrgAdmin{{
+		Login: "jdoe",
+		Name:  "Jane Doe",
+		Email: "jane@example.com",
+		Orgs:  []string{"acme", "acme-labs"},
+		Repos: []string{"acme/api", "acme/web", "acme-labs/lab"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("crossOrgAdmins =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := crossOrgAdmins([]ghOrgData{acme}); got != nil {
+		t.Errorf("crossOrgAdmins of a single org = %+v, want none", got)
+	}
+}
+
+func TestOrphanedRepos(t *testing.T) {
+	d := ghOrgData{
+		Org: "acme",
+		Repos: ghRepoInfo{
+			{Name: "active"}, {Name: "bots"}, {Name: "empty"}, {Name: "gone"}, {Name: "owned"}, {Name: "mixed"},
+		},
+		Admins: map[string]ghCollaborators{
+			"active": {{Login: "jdoe"}},
+			"bots":   {{Login: "dependabot[bot]"}, {Login: "ci-app", Type: "Bot"}},
+			"gone":   {{Login: "left"}, {Login: "banned"}},
+			"owned":  {{Login: "Owner"}, {Login: "dependabot[bot]"}},
+			"mixed":  {{Login: "owner"}, {Login: "left"}, {Login: "jdoe"}},
+		},
+		Names:  map[string]ghNameDetail{"left": {Departed: true}, "banned": {Suspended: true}},
+		Owners: map[string]bool{"owner": true},
+	}
+
+	got := orphanedRepos([]ghOrgData{d})
+	want := []ghOrphanedRepo{
+		{Org: "acme", Repo: "bots", Reason: orphanNoAdmins},
+		{Org: "acme", Repo: "empty", Reason: orphanNoAdmins},
+		{Org: "acme", Repo: "gone", Reason: orphanInactiveAdmins, Admins: []string{"left", "banned"}},
+		{Org: "acme", Repo: "owned", Reason: orphanOwnersOnly, Admins: []string{"Owner"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orphanedRepos =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		l    string
+		t    string
+		want bool
+	}{
+		{"jdoe", "User", false},
+		{"dependabot[bot]", "Bot", true},
+		{"renovate[BOT]", "", true},
+		{"ci-app", "bot", true},
+		{"botanist", "User", false},
+	}
+	for _, tc := range tests {
+		if got := isBot(tc.l, tc.t); got != tc.want {
+			t.Errorf("isBot(%q, %q) = %v, want %v", tc.l, tc.t, got, tc.want)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 #!/bin/bash
 
+# -format sqlite uses github.com/mattn/go-sqlite3 which needs cgo and a C compiler for the
+# target. Cross builds below need CGO_ENABLED=1 and a cross compiler set with CC, otherwise
+# they build without cgo and -format sqlite fails when run
 go build -o ghorg2csv
-#GOOS=darwin go build -o ghorg2csv-mac
-#GOOS=windows go build -o ghorg2csv.exe
+#CGO_ENABLED=1 CC=o64-clang GOOS=darwin go build -o ghorg2csv-mac
+#CGO_ENABLED=1 CC=x86_64-w64-mingw32-gcc GOOS=windows go build -o ghorg2csv.exe
 echo "Building for darwin and windows disabled."
-
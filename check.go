@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"strings"
+)
+
+// Exit codes, kept apart so CI can tell a clean run from violations and from the tool failing.
+// Errors use 2 to match the exit code of the flag package for bad arguments
+const (
+	exitClean      = 0
+	exitViolations = 1
+	exitError      = 2
+)
+
+// JUnit XML report with a test suite per policy rule and a test case per repo the rule applies to
+// see https://github.com/testmoapp/junitxml
+type junitReport struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	Failures  []junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit takes a file name, the policy, the data collected for one or more orgs and the
+// violations found and writes them out as JUnit XML. Repos which follow a rule are passing tests
+func writeJUnit(f string, p *policy, orgs []ghOrgData, found []policyViolation) error {
+	// Group the violations by rule and repo
+	failed := make(map[string][]policyViolation)
+	for _, v := range found {
+		k := v.RuleID + "/" + v.Org + "/" + v.Repo
+		failed[k] = append(failed[k], v)
+	}
+
+	rpt := junitReport{Name: "ghorg2csv policy"}
+	for _, r := range p.Rules {
+		s := junitSuite{Name: r.ID}
+		for _, o := range orgs {
+			for _, repo := range o.Repos {
+				if !ruleApplies(r, repo.Archived, repo.Visibility) {
+					continue
+				}
+				c := junitCase{Name: o.Org + "/" + repo.Name, ClassName: r.ID}
+				for _, v := range failed[r.ID+"/"+o.Org+"/"+repo.Name] {
+					c.Failures = append(c.Failures, junitFailure{
+						Message: v.Message,
+						Type:    v.Severity,
+						Text:    v.Severity + ": " + v.Message,
+					})
+				}
+				s.Tests++
+				if len(c.Failures) > 0 {
+					s.Failures++
+				}
+				s.Cases = append(s.Cases, c)
+			}
+		}
+		rpt.Tests += s.Tests
+		rpt.Failures += s.Failures
+		rpt.Suites = append(rpt.Suites, s)
+	}
+
+	b, err := xml.MarshalIndent(rpt, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(f, append([]byte(xml.Header), append(b, '\n')...), 0644)
+}
+
+// SARIF 2.1.0 log with a result for each policy violation
+// see https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string       `json:"id"`
+	ShortDescription     sarifMessage `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+	Properties struct {
+		Severity string `json:"severity"`
+	} `json:"properties"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	} `json:"physicalLocation"`
+}
+
+// writeSARIF takes a file name, the policy and the violations found and writes them out as SARIF
+func writeSARIF(f string, p *policy, found []policyViolation) error {
+	d := sarifDriver{
+		Name:           "ghorg2csv",
+		Version:        strings.TrimPrefix(ver, "v"),
+		InformationURI: "https://github.com/mtesauro/gh-org-tools",
+	}
+	index := make(map[string]int)
+	for k, r := range p.Rules {
+		sr := sarifRule{ID: r.ID, ShortDescription: sarifMessage{Text: r.Message}}
+		sr.DefaultConfiguration.Level = sarifLevel(r.Severity)
+		sr.Properties.Severity = r.Severity
+		d.Rules = append(d.Rules, sr)
+		index[r.ID] = k
+	}
+
+	run := sarifRun{Tool: sarifTool{Driver: d}, Results: []sarifResult{}}
+	for _, v := range found {
+		res := sarifResult{
+			RuleID:    v.RuleID,
+			RuleIndex: index[v.RuleID],
+			Level:     sarifLevel(v.Severity),
+			Message:   sarifMessage{Text: v.Org + "/" + v.Repo + ": " + v.Message},
+		}
+		l := sarifLocation{}
+		l.PhysicalLocation.ArtifactLocation.URI = v.URL
+		if len(v.URL) == 0 {
+			l.PhysicalLocation.ArtifactLocation.URI = v.Org + "/" + v.Repo
+		}
+		res.Locations = append(res.Locations, l)
+		run.Results = append(run.Results, res)
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+	b, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(f, append(b, '\n'), 0644)
+}
+
+// sarifLevel maps the severity of a policy rule to a SARIF level
+func sarifLevel(s string) string {
+	switch s {
+	case "critical", "high":
+		return "error"
+	case "medium":
+		return "warning"
+	}
+
+	return "note"
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Struct to hold a single column of the main CSV
+type csvColumn struct {
+	Key   string
+	Label string
+	value func(o ghOrgData, k int) string
+}
+
+// Default CSV columns, done in 'long' form to make turning items off and on easy
+var defaultColumns = []string{
+	"org",               // e.g. my-github-org
+	"full_name",         // e.g. org/repo-name
+	"name",              // e.g. repo-name
+	"short_description", // Full description trimmed down to 46 characters
+	//"repo_type",           // 'public', 'private', 'internal' or 'public, fork'
+	"private",                // true or false
+	"fork",                   // true or false
+	"visibility",             // public or private
+	"updated_at:Last Update", // e.g. 2022-06-13T07:59:05Z
+	"admins",                 // List of all the admins
+}
+
+// Columns which aren't a field of ghRepoInfo
+var computedColumns = map[string]csvColumn{
+	"org": {Label: "Org", value: func(o ghOrgData, k int) string {
+		return o.Org
+	}},
+	"short_description": {Label: "Short Description", value: func(o ghOrgData, k int) string {
+		desc := o.Repos[k].Description
+		if len(desc) > 46 {
+			desc = desc[0:45]
+		}
+		return desc
+	}},
+	"admins": {Label: "Repo Admins", value: func(o ghOrgData, k int) string {
+		return strings.TrimRight(listAdmins(o.Repos[k].Name, o.Admins, o.Names), ", ")
+	}},
+	"admin_count": {Label: "Admin Count", value: func(o ghOrgData, k int) string {
+		return strconv.Itoa(len(o.Admins[o.Repos[k].Name]))
+	}},
+	"collaborator_count": {Label: "Collaborator Count", value: func(o ghOrgData, k int) string {
+		return strconv.Itoa(len(o.Collabs[o.Repos[k].Name]))
+	}},
+}
+
+// Default header labels for ghRepoInfo fields where the title cased field name won't do
+var columnLabels = map[string]string{
+	"html_url":        "URL",
+	"license.spdx_id": "License",
+	"owner.login":     "Owner",
+	"pushed_at":       "Last Push",
+	"updated_at":      "Last Update",
+}
+
+// parseColumns takes a comma-separated list of columns, each either a field name or
+// a field name and header label separated by a colon e.g. "full_name,pushed_at:Pushed",
+// and returns the CSV columns in the order given. An empty list returns the default columns.
+// Fields of ghRepoInfo are named by their JSON name with nested fields joined by a dot
+// e.g. license.spdx_id or owner.login
+func parseColumns(c string) ([]csvColumn, error) {
+	list := defaultColumns
+	if len(strings.TrimSpace(c)) > 0 {
+		list = strings.Split(c, ",")
+	}
+
+	fields := repoFields()
+	var cols []csvColumn
+	for _, v := range list {
+		// Split off any custom label
+		key := strings.TrimSpace(v)
+		label := ""
+		if i := strings.Index(key, ":"); i >= 0 {
+			label = strings.TrimSpace(key[i+1:])
+			key = strings.TrimSpace(key[:i])
+		}
+		key = strings.ToLower(key)
+		if len(key) == 0 {
+			continue
+		}
+
+		// Look up the column
+		col, exists := computedColumns[key]
+		if !exists {
+			idx, found := fields[key]
+			if !found {
+				return nil, errors.New(fmt.Sprintf("Unknown column '%s', valid columns are: %s", key, strings.Join(columnNames(), ", ")))
+			}
+			col = csvColumn{Label: columnLabel(key), value: fieldValue(idx)}
+		}
+		col.Key = key
+		if len(label) > 0 {
+			col.Label = label
+		}
+		cols = append(cols, col)
+	}
+	if len(cols) == 0 {
+		return nil, errors.New("No columns provided")
+	}
+
+	return cols, nil
+}
+
+// repoFields returns a map of the JSON name of each field of ghRepoInfo to the index
+// path for the field. Nested structs are flattened with a dot e.g. license.spdx_id
+func repoFields() map[string][]int {
+	fields := make(map[string][]int)
+	addFields(fields, reflect.TypeOf(ghRepoInfo{}).Elem(), "", nil)
+
+	return fields
+}
+
+// addFields adds the fields of struct type t to the fields map, prefixing their names with p
+func addFields(fields map[string][]int, t reflect.Type, p string, idx []int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if len(name) == 0 || name == "-" {
+			continue
+		}
+		path := append(append([]int{}, idx...), i)
+		if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}) {
+			addFields(fields, f.Type, p+name+".", path)
+			continue
+		}
+		fields[p+name] = path
+	}
+}
+
+// fieldValue returns a function to pull the ghRepoInfo field at index path idx out of a repo
+// and format it for the CSV
+func fieldValue(idx []int) func(o ghOrgData, k int) string {
+	return func(o ghOrgData, k int) string {
+		v := reflect.ValueOf(o.Repos[k]).FieldByIndex(idx)
+		switch x := v.Interface().(type) {
+		case string:
+			return x
+		case bool:
+			return strconv.FormatBool(x)
+		case int:
+			return strconv.Itoa(x)
+		case time.Time:
+			if x.IsZero() {
+				return ""
+			}
+			return x.Format(time.RFC3339)
+		case []string:
+			return strings.Join(x, ", ")
+		}
+		return fmt.Sprintf("%v", v.Interface())
+	}
+}
+
+// columnLabel returns the default header label for a ghRepoInfo field
+// e.g. stargazers_count becomes Stargazers Count
+func columnLabel(key string) string {
+	if l, exists := columnLabels[key]; exists {
+		return l
+	}
+
+	words := strings.Fields(strings.NewReplacer("_", " ", ".", " ").Replace(key))
+	for k := range words {
+		words[k] = strings.ToUpper(words[k][:1]) + words[k][1:]
+	}
+
+	return strings.Join(words, " ")
+}
+
+// columnNames returns the names of all the columns available for the CSV, sorted
+func columnNames() []string {
+	var names []string
+	for k := range computedColumns {
+		names = append(names, k)
+	}
+	for k := range repoFields() {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseColumns(t *testing.T) {
+	// Nested fields are anonymous structs so the repo is built from the API's JSON
+	d := ghOrgData{
+		Org:     "acme",
+		Collabs: map[string]ghCollaborators{"api": {{Login: "jdoe"}, {Login: "asmith"}}},
+		Admins:  map[string]ghCollaborators{"api": {{Login: "jdoe"}}},
+		Names:   map[string]ghNameDetail{"jdoe": {Name: "Jane Doe"}},
+	}
+	err := json.Unmarshal([]byte(`[{"name":"api","full_name":"acme/api","private":true,"visibility":"private",`+
+		`"description":"An API with a description much longer than forty six characters",`+
+		`"updated_at":"2022-06-13T07:59:05Z","license":{"spdx_id":"MIT"},"owner":{"login":"acme"}}]`), &d.Repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		columns string
+		labels  []string
+		values  []string
+		wantErr bool
+	}{
+		{
+			name:   "default columns",
+			labels: []string{"Org", "Full Name", "Name", "Short Description", "Private", "Fork", "Visibility", "Last Update", "Repo Admins"},
+			values: []string{"acme", "acme/api", "api", "An API with a description much longer than fo", "true", "false", "private", "2022-06-13T07:59:05Z", "jdoe (Jane Doe)"},
+		},
+		{
+			name:    "custom labels",
+			columns: "full_name:Repo, visibility : Vis ,admins:Owners",
+			labels:  []string{"Repo", "Vis", "Owners"},
+			values:  []string{"acme/api", "private", "jdoe (Jane Doe)"},
+		},
+		{
+			name:    "nested fields",
+			columns: "license.spdx_id,Owner.Login",
+			labels:  []string{"License", "Owner"},
+			values:  []string{"MIT", "acme"},
+		},
+		{
+			name:    "computed columns",
+			columns: "admin_count,collaborator_count",
+			labels:  []string{"Admin Count", "Collaborator Count"},
+			values:  []string{"1", "2"},
+		},
+		{name: "blank entries skipped", columns: "name,,", labels: []string{"Name"}, values: []string{"api"}},
+		{name: "unknown column", columns: "name,stars", wantErr: true},
+		{name: "no columns", columns: ",:Label", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cols, err := parseColumns(tc.columns)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseColumns(%q) returned error %v, want error %v", tc.columns, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			var labels, values []string
+			for _, c := range cols {
+				labels = append(labels, c.Label)
+				values = append(values, c.value(d, 0))
+			}
+			if !reflect.DeepEqual(labels, tc.labels) {
+				t.Errorf("parseColumns(%q) labels = %q, want %q", tc.columns, labels, tc.labels)
+			}
+			if !reflect.DeepEqual(values, tc.values) {
+				t.Errorf("parseColumns(%q) values = %q, want %q", tc.columns, values, tc.values)
+			}
+		})
+	}
+}
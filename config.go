@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Prefix of the environmental variables which set flags e.g. GHORG2CSV_API_URL for -api-url
+const envPrefix = "GHORG2CSV_"
+
+// Struct to hold a config file of the settings used when they aren't provided as flags or
+// environmental variables
+// e.g.
+//
+//	orgs: [my-org, my-other-org]
+//	api_url: https://github.example.com/api/v3
+//	output:
+//	  format: json
+//	  path: reports/org-info.csv
+//	  columns: [full_name, visibility, admins]
+//	filters:
+//	  visibility: [public, internal]
+//	  exclude_archived: true
+//	  exclude: [sandbox-*]
+//	concurrency: 4
+//	auth:
+//	  method: token-file
+//	  token_file: /run/secrets/github-token
+type config struct {
+	Orgs       []string `yaml:"orgs"`
+	Enterprise string   `yaml:"enterprise"`
+	User       string   `yaml:"user"`
+	APIURL     string   `yaml:"api_url"`
+	Output     struct {
+		Format string `yaml:"format"`
+		// Name of the report, ending in .csv which is swapped for the extension of other formats
+		Path    string   `yaml:"path"`
+		Columns []string `yaml:"columns"`
+		Layout  string   `yaml:"layout"`
+	} `yaml:"output"`
+	Filters struct {
+		Visibility      []string `yaml:"visibility"`
+		ExcludeArchived bool     `yaml:"exclude_archived"`
+		ExcludeForks    bool     `yaml:"exclude_forks"`
+		Include         []string `yaml:"include"`
+		Exclude         []string `yaml:"exclude"`
+	} `yaml:"filters"`
+	Concurrency int `yaml:"concurrency"`
+	Auth        struct {
+		Method    string `yaml:"method"`
+		TokenEnv  string `yaml:"token_env"`
+		TokenFile string `yaml:"token_file"`
+	} `yaml:"auth"`
+}
+
+// loadConfig reads a YAML config file, rejecting settings it doesn't know about
+func loadConfig(f string) (*config, error) {
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	c := config{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	err = dec.Decode(&c)
+	if err != nil && err != io.EOF {
+		return nil, errors.New(fmt.Sprintf("Problem reading config file %s was: %v", f, err))
+	}
+
+	return &c, nil
+}
+
+// flagValues returns the settings of the config file as the values of the flags they set,
+// keyed by flag name and leaving out settings which weren't provided
+func (c *config) flagValues() map[string]string {
+	v := map[string]string{
+		"org":        strings.Join(c.Orgs, ","),
+		"enterprise": c.Enterprise,
+		"user":       c.User,
+		"api-url":    c.APIURL,
+		"format":     c.Output.Format,
+		"csv":        c.Output.Path,
+		"columns":    strings.Join(c.Output.Columns, ","),
+		"layout":     c.Output.Layout,
+		"visibility": strings.Join(c.Filters.Visibility, ","),
+		"include":    strings.Join(c.Filters.Include, ","),
+		"exclude":    strings.Join(c.Filters.Exclude, ","),
+		"auth":       c.Auth.Method,
+		"token-env":  c.Auth.TokenEnv,
+		"token-file": c.Auth.TokenFile,
+	}
+	if c.Filters.ExcludeArchived {
+		v["exclude-archived"] = "true"
+	}
+	if c.Filters.ExcludeForks {
+		v["exclude-forks"] = "true"
+	}
+	if c.Concurrency > 0 {
+		v["concurrency"] = strconv.Itoa(c.Concurrency)
+	}
+	for k := range v {
+		if len(v[k]) == 0 {
+			delete(v, k)
+		}
+	}
+
+	return v
+}
+
+// envName takes the name of a flag and returns the environmental variable which sets it
+// e.g. api-url becomes GHORG2CSV_API_URL
+func envName(n string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(n, "-", "_"))
+}
+
+// configFlags defines the flag for the config file
+func configFlags(fs *flag.FlagSet, c *string) {
+	fs.StringVar(c, "config", "", "Provide a YAML config file of settings e.g. orgs, api_url, output, filters,\n"+
+		"concurrency and auth. Flags not provided are read from environmental\n"+
+		"variables named like "+envName("api-url")+" for -api-url, then from the config file")
+}
+
+// Ensure every flag not provided on the command line is set from its environmental variable or,
+// failing that, from the config file provided with -config, so flags take precedence over the
+// environment which takes precedence over the config file
+func configArgs(fs *flag.FlagSet) {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	// The config file itself may come from the environment
+	file := fs.Lookup("config").Value.String()
+	if v, present := os.LookupEnv(envName("config")); present && !set["config"] {
+		file = v
+	}
+	values := make(map[string]string)
+	if len(file) > 0 {
+		c, err := loadConfig(file)
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			os.Exit(exitError)
+		}
+		values = c.flagValues()
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || f.Name == "config" {
+			return
+		}
+		from := "environmental variable " + envName(f.Name)
+		v, present := os.LookupEnv(envName(f.Name))
+		if !present {
+			from = "config file " + file
+			v, present = values[f.Name]
+		}
+		if !present {
+			return
+		}
+		err := fs.Set(f.Name, v)
+		if err != nil {
+			fmt.Printf("ERROR: Invalid value '%s' for -%s from the %s\n", v, f.Name, from)
+			os.Exit(exitError)
+		}
+	})
+}
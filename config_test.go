@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testConfigFile writes a config file and returns its name
+func testConfigFile(t *testing.T, content string) string {
+	f := filepath.Join(t.TempDir(), "config.yaml")
+	err := ioutil.WriteFile(f, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"org":              "GHORG2CSV_ORG",
+		"api-url":          "GHORG2CSV_API_URL",
+		"exclude-archived": "GHORG2CSV_EXCLUDE_ARCHIVED",
+	}
+	for n, want := range tests {
+		if got := envName(n); got != want {
+			t.Errorf("envName(%q) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "every setting",
+			yaml: "orgs: [acme, acme-labs]\nenterprise: big\nuser: jdoe\napi_url: https://github.example.com/api/v3\n" +
+				"output:\n  format: json\n  path: reports/org-info.csv\n  columns: [full_name, admins]\n  layout: long\n" +
+				"filters:\n  visibility: [public, internal]\n  exclude_archived: true\n  exclude_forks: true\n" +
+				"  include: [api-*]\n  exclude: [sandbox-*, tmp-*]\n" +
+				"concurrency: 4\nauth:\n  method: token-file\n  token_env: GH_PAT\n  token_file: /run/secrets/github-token\n",
+			want: map[string]string{
+				"org":              "acme,acme-labs",
+				"enterprise":       "big",
+				"user":             "jdoe",
+				"api-url":          "https://github.example.com/api/v3",
+				"format":           "json",
+				"csv":              "reports/org-info.csv",
+				"columns":          "full_name,admins",
+				"layout":           "long",
+				"visibility":       "public,internal",
+				"exclude-archived": "true",
+				"exclude-forks":    "true",
+				"include":          "api-*",
+				"exclude":          "sandbox-*,tmp-*",
+				"concurrency":      "4",
+				"auth":             "token-file",
+				"token-env":        "GH_PAT",
+				"token-file":       "/run/secrets/github-token",
+			},
+		},
+		{name: "settings not provided are left out", yaml: "orgs: [acme]\n", want: map[string]string{"org": "acme"}},
+		{name: "empty", yaml: "", want: map[string]string{}},
+		{name: "unknown setting", yaml: "orgs: [acme]\ntoken: secret\n", wantErr: true},
+		{name: "wrong type", yaml: "concurrency: lots\n", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := loadConfig(testConfigFile(t, tc.yaml))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("loadConfig returned error %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if got := c.flagValues(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("flagValues = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfigArgs(t *testing.T) {
+	file := testConfigFile(t, "orgs: [file-org]\noutput:\n  format: json\nconcurrency: 4\n")
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want map[string]string
+	}{
+		{
+			name: "no config",
+			want: map[string]string{"org": "", "format": "csv", "concurrency": "1", "exclude-archived": "false"},
+		},
+		{
+			name: "config file",
+			args: []string{"-config", file},
+			want: map[string]string{"org": "file-org", "format": "json", "concurrency": "4", "exclude-archived": "false"},
+		},
+		{
+			name: "config file from the environment",
+			env:  map[string]string{"GHORG2CSV_CONFIG": file},
+			want: map[string]string{"org": "file-org", "format": "json", "concurrency": "4", "exclude-archived": "false"},
+		},
+		{
+			name: "environment over config file",
+			args: []string{"-config", file},
+			env:  map[string]string{"GHORG2CSV_ORG": "env-org", "GHORG2CSV_EXCLUDE_ARCHIVED": "true"},
+			want: map[string]string{"org": "env-org", "format": "json", "concurrency": "4", "exclude-archived": "true"},
+		},
+		{
+			name: "flags over environment and config file",
+			args: []string{"-config", file, "-org", "flag-org", "-concurrency", "2"},
+			env:  map[string]string{"GHORG2CSV_ORG": "env-org", "GHORG2CSV_CONCURRENCY": "8"},
+			want: map[string]string{"org": "flag-org", "format": "json", "concurrency": "2", "exclude-archived": "false"},
+		},
+		{
+			name: "flag set to its default over environment",
+			args: []string{"-format", "csv"},
+			env:  map[string]string{"GHORG2CSV_FORMAT": "xlsx"},
+			want: map[string]string{"org": "", "format": "csv", "concurrency": "1", "exclude-archived": "false"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			var c, org, format string
+			var concurrency int
+			var archived bool
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			configFlags(fs, &c)
+			fs.StringVar(&org, "org", "", "")
+			fs.StringVar(&format, "format", "csv", "")
+			fs.IntVar(&concurrency, "concurrency", 1, "")
+			fs.BoolVar(&archived, "exclude-archived", false, "")
+			err := fs.Parse(tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			configArgs(fs)
+			got := map[string]string{}
+			for k := range tc.want {
+				got[k] = fs.Lookup(k).Value.String()
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("configArgs set %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
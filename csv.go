@@ -2,6 +2,8 @@ package main
 
 import (
 	"encoding/csv"
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -9,7 +11,7 @@ import (
 )
 
 //
-func writeCSV(f string, repos *ghRepoInfo, adm map[string]ghCollaborators, lu map[string]ghNameDetail) error {
+func writeCSV(f string, orgs []ghOrgData, cols []csvColumn) error {
 	// Create the CSV file
 	fi, err := os.Create(f)
 	if err != nil {
@@ -23,46 +25,33 @@ func writeCSV(f string, repos *ghRepoInfo, adm map[string]ghCollaborators, lu ma
 
 	// Create the header row
 	var header []string
-	// Do header in 'long' for to make turning items off and on easy
-	header = append(header,
-		"Full Name",         // e.g. org/repo-name
-		"Name",              // e.g. repo-name
-		"Short Description", // Full description trimmed down to 46 characters
-		//"Repo Type",           // 'public', 'private', 'internal' or 'public, fork'
-		"Private",     // true or false
-		"Fork",        // true or false
-		"Visibility",  // public or private
-		"Last Update", // e.g. 2022-06-13T07:59:05Z
-		"Repo Admins", // List of all the admins
-	)
+	for _, c := range cols {
+		header = append(header, c.Label)
+	}
 	err = csvFile.Write(header)
 	if err != nil {
 		return err
 	}
 
 	// Add the collected details to the CSV
-	for _, v := range *repos {
+	for _, o := range orgs {
+		err = writeOrgRows(csvFile, o, cols)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeOrgRows writes a CSV line for each repo in the provided org data
+func writeOrgRows(csvFile *csv.Writer, o ghOrgData, cols []csvColumn) error {
+	for k := range o.Repos {
 		// Slice of string for each CSV line
 		var line []string
-
-		// Setup some values first
-		desc := v.Description
-		if len(v.Description) > 46 {
-			desc = v.Description[0:45]
+		for _, c := range cols {
+			line = append(line, c.value(o, k))
 		}
-		admList := listAdmins(v.Name, adm, lu)
-
-		// Create a CSV line
-		line = append(line,
-			v.FullName,
-			v.Name,
-			desc,
-			strconv.FormatBool(v.Private),
-			strconv.FormatBool(v.Fork),
-			v.Visibility,
-			v.UpdatedAt.Format(time.RFC3339),
-			strings.TrimRight(admList, ","),
-		)
 
 		// Write out the current line
 		err := csvFile.Write(line)
@@ -74,41 +63,428 @@ func writeCSV(f string, repos *ghRepoInfo, adm map[string]ghCollaborators, lu ma
 	return nil
 }
 
+// writeLongCSV takes a file name, the data collected for one or more orgs and the repo columns
+// to include and writes a CSV with one row per (repo, user, permission, source). The repo
+// columns are repeated on each row, except for the admins column which the rows replace.
+// Repos without any collaborators get a single row with empty user columns
+func writeLongCSV(f string, orgs []ghOrgData, cols []csvColumn) error {
+	// Create the CSV file
+	fi, err := os.Create(f)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	// Setup a new CSV writer
+	csvFile := csv.NewWriter(fi)
+	defer csvFile.Flush()
+
+	// Drop the admins column since each row is a single user
+	var repoCols []csvColumn
+	for _, c := range cols {
+		if c.Key != "admins" {
+			repoCols = append(repoCols, c)
+		}
+	}
+
+	// Create the header row
+	var header []string
+	for _, c := range repoCols {
+		header = append(header, c.Label)
+	}
+	header = append(header,
+		"Login",      // Github username
+		"User Name",  // Name from the user's Github profile, only looked up for admins
+		"Email",      // Email for the user, only looked up for admins
+		"Permission", // e.g. admin, maintain, write, triage, read or a custom role
+		"Source",     // direct, team-or-org or outside-collaborator
+	)
+	withManager := len(orgs) > 0 && orgs[0].Roster != nil
+	if withManager {
+		header = append(header, "Manager") // Manager from the employee roster
+	}
+	err = csvFile.Write(header)
+	if err != nil {
+		return err
+	}
+
+	// Add a line for each collaborator of each repo
+	for _, o := range orgs {
+		for k, r := range o.Repos {
+			var repoVals []string
+			for _, c := range repoCols {
+				repoVals = append(repoVals, c.value(o, k))
+			}
+
+			// Keep repos without any collaborators with empty user columns
+			if len(o.Collabs[r.Name]) == 0 {
+				line := append(repoVals, "", "", "", "", "")
+				if withManager {
+					line = append(line, "")
+				}
+				err := csvFile.Write(line)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			for _, v := range o.Collabs[r.Name] {
+				line := append(append([]string{}, repoVals...),
+					v.Login,
+					o.Names[v.Login].Name,
+					o.Names[v.Login].Email,
+					v.RoleName,
+					accessSource(o, r.Name, v.Login),
+				)
+				if withManager {
+					line = append(line, rosterManager(&o, v.Login))
+				}
+				err := csvFile.Write(line)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 //
 func listAdmins(repo string, adm map[string]ghCollaborators, lu map[string]ghNameDetail) string {
 	// Find the admins for the current repo
 	var list string
 	for _, v := range adm[repo] {
-		list += v.Login + checkDetails(lu[v.Login].Name, lu[v.Login].Email)
+		list += v.Login + checkDetails(lu[v.Login].Name, labelEmail(lu[v.Login]), labelSSO(lu[v.Login]), labelManager(lu[v.Login]))
 	}
 
 	return list
 }
 
 //
-func checkDetails(n string, e string) string {
-	// " (" + n + " - " + e + ") "
-	// Check name
-	gotName := false
-	if len(n) > 0 {
-		gotName = true
+func checkDetails(n string, e string, s string, m string) string {
+	// " (" + n + " - " + e + " - " + s + " - " + m + "), "
+	// Keep the details that were found
+	var found []string
+	for _, v := range []string{n, e, s, m} {
+		if len(v) > 0 {
+			found = append(found, v)
+		}
 	}
 
-	gotEmail := false
-	if len(e) > 0 {
-		gotName = true
+	// Return appropriate details
+	if len(found) == 0 {
+		return ", "
 	}
 
-	// Return appropriate details
-	if gotName && gotEmail {
-		return " (" + n + " - " + e + "), "
+	return " (" + strings.Join(found, " - ") + "), "
+}
+
+// writeCrossOrgCSV takes a file name and the data collected for multiple orgs and writes
+// a CSV of the users who are a repo admin in more than one of those orgs
+func writeCrossOrgCSV(f string, orgs []ghOrgData) error {
+	header := []string{
+		"Login",        // Github username
+		"Name",         // Name from the user's Github profile
+		"Email",        // Email for the user
+		"Email Source", // Where the email came from e.g. profile, verified-domain or commit
+		"Org Count",    // Number of orgs the user is a repo admin in
+		"Orgs",         // List of those orgs
+		"Admin Repos",  // List of all the repos the user is an admin of e.g. org/repo-name
 	}
-	if gotName && !gotEmail {
-		return " (" + n + "), "
+
+	// Add a line for each admin found in more than one org
+	var rows [][]string
+	for _, v := range crossOrgAdmins(orgs) {
+		rows = append(rows, []string{
+			v.Login,
+			v.Name,
+			v.Email,
+			v.EmailSource,
+			strconv.Itoa(len(v.Orgs)),
+			strings.Join(v.Orgs, ", "),
+			strings.Join(v.Repos, ", "),
+		})
 	}
-	if !gotName && gotEmail {
-		return " (" + e + "), "
+
+	return writeCSVRows(f, header, rows)
+}
+
+// reportCSVName takes the name of the CSV for the main report and the suffix of an additional
+// CSV and returns the name to use for it e.g. org-info.csv and sso-unlinked becomes
+// org-info-sso-unlinked.csv
+func reportCSVName(f string, suffix string) string {
+	return strings.TrimSuffix(f, ".csv") + "-" + suffix + ".csv"
+}
+
+// writeCSVRows takes a file name, a header and rows and writes them out as a CSV
+func writeCSVRows(f string, header []string, rows [][]string) error {
+	// Create the CSV file
+	fi, err := os.Create(f)
+	if err != nil {
+		return err
 	}
+	defer fi.Close()
+
+	// Setup a new CSV writer
+	csvFile := csv.NewWriter(fi)
+	defer csvFile.Flush()
+
+	err = csvFile.Write(header)
+	if err != nil {
+		return err
+	}
+	for _, v := range rows {
+		err = csvFile.Write(v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	return ", "
+// labelEmail returns the email for a user with the source of the email appended
+// e.g. jane@example.com [verified-domain] or an empty string if there's no email
+func labelEmail(d ghNameDetail) string {
+	if len(d.Email) == 0 {
+		return ""
+	}
+	if len(d.EmailSource) == 0 {
+		return d.Email
+	}
+
+	return d.Email + " [" + d.EmailSource + "]"
+}
+
+// labelSSO returns the SAML SSO identity for a user e.g. SSO: jane@example.com, a flag
+// when the user has no linked identity or an empty string if SSO identities weren't collected
+func labelSSO(d ghNameDetail) string {
+	switch d.SSOStatus {
+	case ssoLinked:
+		return "SSO: " + d.SSONameID
+	case ssoUnlinked:
+		return "SSO: NOT LINKED"
+	}
+
+	return ""
+}
+
+// labelManager returns the manager of a user from the employee roster e.g. Manager: jane@example.com
+// or an empty string if there's no roster or the user isn't on it
+func labelManager(d ghNameDetail) string {
+	if len(d.Manager) == 0 {
+		return ""
+	}
+
+	return "Manager: " + d.Manager
+}
+
+// writeSSOCSV takes a file name and the data collected for one or more orgs and writes
+// a CSV of the org members who don't have a linked SAML SSO identity
+func writeSSOCSV(f string, orgs []ghOrgData) error {
+	// Add a line for each member without a linked identity
+	var rows [][]string
+	for _, o := range orgs {
+		for _, v := range o.SSOUnlinked {
+			rows = append(rows, []string{o.Org, v, "NOT LINKED"})
+		}
+	}
+
+	return writeCSVRows(f, []string{"Org", "Login", "SSO Identity"}, rows)
+}
+
+// writeInvitesCSV takes a file name and the data collected for one or more orgs and writes
+// a CSV of the pending org and repo invitations
+func writeInvitesCSV(f string, orgs []ghOrgData) error {
+	header := []string{
+		"Org",        // e.g. my-github-org
+		"Level",      // org or repo
+		"Repo",       // Empty for org invitations
+		"Invitee",    // Github username or email
+		"Inviter",    // Github username
+		"Role",       // e.g. admin, direct_member, write
+		"Invited At", // e.g. 2022-06-13T07:59:05Z
+		"Age (Days)", // Days since the invitation was sent
+		"Stale",      // true if the invitation should be revoked
+		"ID",         // Github's ID for the invitation, used to cancel it
+	}
+
+	// Add a line for each invitation
+	var rows [][]string
+	for _, o := range orgs {
+		for _, v := range o.Invitations {
+			rows = append(rows, []string{
+				o.Org,
+				v.Level,
+				v.Repo,
+				v.Invitee,
+				v.Inviter,
+				v.Role,
+				v.CreatedAt.Format(time.RFC3339),
+				strconv.Itoa(v.AgeDays),
+				strconv.FormatBool(v.Stale),
+				strconv.Itoa(v.ID),
+			})
+		}
+	}
+
+	return writeCSVRows(f, header, rows)
+}
+
+// writeOrphansCSV takes a file name and the data collected for one or more orgs and writes
+// a CSV of the repos without an active admin who isn't an org owner
+func writeOrphansCSV(f string, orgs []ghOrgData) error {
+	header := []string{
+		"Org",    // e.g. my-github-org
+		"Repo",   // e.g. my-repo
+		"Reason", // no-admins, inactive-admins or owners-only
+		"Admins", // Admins of the repo other than bots
+	}
+
+	// Add a line for each orphaned repo
+	var rows [][]string
+	for _, v := range orphanedRepos(orgs) {
+		rows = append(rows, []string{v.Org, v.Repo, v.Reason, strings.Join(v.Admins, ", ")})
+	}
+
+	return writeCSVRows(f, header, rows)
+}
+
+// writeRosterCSV takes a file name and the data collected for one or more orgs and writes
+// a CSV of the accounts with access that aren't on the employee roster or have left
+func writeRosterCSV(f string, orgs []ghOrgData) error {
+	header := []string{
+		"Org",     // e.g. my-github-org
+		"Login",   // Github username
+		"Access",  // e.g. member; admin of 2 repos; collaborator on 5 repos
+		"Finding", // not-on-roster, terminated or unmatched-no-email
+		"Status",  // Status from the roster
+		"Manager", // Manager from the roster
+	}
+
+	// Add a line for each account
+	var rows [][]string
+	for _, v := range rosterExceptions(orgs) {
+		rows = append(rows, []string{v.Org, v.Login, v.Access, v.Finding, v.Status, v.Manager})
+	}
+
+	return writeCSVRows(f, header, rows)
+}
+
+// writeRecommendationsCSV takes a file name and the data collected for one or more orgs and
+// writes a CSV of the admins recommended a lower role with the evidence for it
+func writeRecommendationsCSV(f string, orgs []ghOrgData) error {
+	header := []string{
+		"Org",            // e.g. my-github-org
+		"Repo",           // e.g. my-repo
+		"Login",          // Github username
+		"Current Role",   // e.g. admin
+		"Source",         // direct or outside-collaborator
+		"Suggested Role", // triage if they review or work on issues, otherwise read
+		"Last Activity",  // Date of their last review or issue activity, if any
+		"Evidence",       // Why the lower role is suggested
+	}
+
+	// Add a line for each recommendation
+	var rows [][]string
+	for _, o := range orgs {
+		for _, v := range o.Recommendations {
+			rows = append(rows, []string{v.Org, v.Repo, v.Login, v.CurrentRole, v.Source, v.SuggestedRole, v.LastActivity, v.Evidence})
+		}
+	}
+
+	return writeCSVRows(f, header, rows)
+}
+
+// writeCSVReports takes a pointer to ghAPIClient and the data collected for one or more orgs
+// and writes the main CSV plus any additional CSVs for the data that was collected
+func writeCSVReports(g *ghAPIClient, orgs []ghOrgData) error {
+	// Generate the CSV and write it out.
+	csvTime := time.Now()
+	cols := g.Columns
+	if cols == nil {
+		cols, _ = parseColumns("")
+	}
+	var err error
+	if g.Layout == layoutLong {
+		err = writeLongCSV(g.File, orgs, cols)
+	} else {
+		err = writeCSV(g.File, orgs, cols)
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem writing CSV file was: %v", err))
+	}
+	fmt.Printf("Write CSV done in %v\n", time.Since(csvTime))
+
+	// Add a list of pending invitations
+	if g.Invitations {
+		inviteTime := time.Now()
+		err = writeInvitesCSV(reportCSVName(g.File, "invitations"), orgs)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing invitations CSV file was: %v", err))
+		}
+		fmt.Printf("Write invitations CSV done in %v\n", time.Since(inviteTime))
+	}
+
+	// Add a cross-org view of admins when reporting on more than one org
+	if len(orgs) > 1 {
+		crossTime := time.Now()
+		err = writeCrossOrgCSV(reportCSVName(g.File, "cross-org-admins"), orgs)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing cross-org CSV file was: %v", err))
+		}
+		fmt.Printf("Write cross-org CSV done in %v\n", time.Since(crossTime))
+	}
+
+	return nil
+}
+
+// writeFindingsCSVs takes a pointer to ghAPIClient and the data collected for one or more orgs
+// and writes the CSVs of findings asked for with flags, whatever the format of the main report
+func writeFindingsCSVs(g *ghAPIClient, orgs []ghOrgData) error {
+	// Name the CSVs after the main report e.g. org-info.json gives org-info-orphaned-repos.csv
+	base := strings.TrimSuffix(g.File, "."+g.Format)
+
+	// Add a list of org members without a linked SAML SSO identity
+	if g.SSO {
+		ssoTime := time.Now()
+		err := writeSSOCSV(reportCSVName(base, "sso-unlinked"), orgs)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing SSO CSV file was: %v", err))
+		}
+		fmt.Printf("Write SSO CSV done in %v\n", time.Since(ssoTime))
+	}
+
+	// Add a list of repos without an active admin
+	if g.Orphans {
+		orphanTime := time.Now()
+		err := writeOrphansCSV(reportCSVName(base, "orphaned-repos"), orgs)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing orphaned repos CSV file was: %v", err))
+		}
+		fmt.Printf("Write orphaned repos CSV done in %v\n", time.Since(orphanTime))
+	}
+
+	// Add a list of admins who could do with a lower role
+	if g.LeastPrivilegeDays > 0 {
+		recTime := time.Now()
+		err := writeRecommendationsCSV(reportCSVName(base, "least-privilege"), orgs)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing least-privilege CSV file was: %v", err))
+		}
+		fmt.Printf("Write least-privilege CSV done in %v\n", time.Since(recTime))
+	}
+
+	// Add a list of accounts that aren't on the employee roster or have left
+	if g.Roster != nil {
+		rosterTime := time.Now()
+		err := writeRosterCSV(reportCSVName(base, "roster-exceptions"), orgs)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing roster exceptions CSV file was: %v", err))
+		}
+		fmt.Printf("Write roster exceptions CSV done in %v\n", time.Since(rosterTime))
+	}
+
+	return nil
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testOrgData returns the data of an org with the repos and collaborators from JSON as
+// returned by the Github API, keyed by repo name for the collaborators
+func testOrgData(t *testing.T, org string, repos string, collabs map[string]string) ghOrgData {
+	d := ghOrgData{Org: org, Collabs: make(map[string]ghCollaborators), Admins: make(map[string]ghCollaborators)}
+	err := json.Unmarshal([]byte(repos), &d.Repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range collabs {
+		c := ghCollaborators{}
+		err = json.Unmarshal([]byte(v), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Collabs[k] = c
+	}
+
+	return d
+}
+
+// readTestCSV returns the rows of a CSV written by a test
+func readTestCSV(t *testing.T, f string) [][]string {
+	fi, err := os.Open(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fi.Close()
+	rows, err := csv.NewReader(fi).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rows
+}
+
+func TestWriteLongCSV(t *testing.T) {
+	d := testOrgData(t, "acme",
+		`[{"name":"api","full_name":"acme/api"},{"name":"orphan","full_name":"acme/orphan"}]`,
+		map[string]string{"api": `[{"login":"jdoe","role_name":"admin"},{"login":"asmith","role_name":"write"}]`})
+	d.Direct = map[string]map[string]bool{"api": {"jdoe": true}}
+	cols, err := parseColumns("full_name,admins")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := filepath.Join(t.TempDir(), "long.csv")
+	err = writeLongCSV(f, []ghOrgData{d}, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readTestCSV(t, f)
+	want := [][]string{
+		{"Full Name", "Login", "User Name", "Email", "Permission", "Source"},
+		{"acme/api", "jdoe", "", "", "admin", "direct"},
+		{"acme/api", "asmith", "", "", "write", "team-or-org"},
+		{"acme/orphan", "", "", "", "", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("writeLongCSV wrote\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestWriteFindingsCSVs(t *testing.T) {
+	d := ghOrgData{Org: "acme", Repos: ghRepoInfo{{Name: "api"}}, Members: []string{"jdoe"}, Roster: &roster{},
+		SSOUnlinked: []string{"jdoe"}}
+	d.Recommendations = []ghRecommendation{{Org: "acme", Repo: "api", Login: "jdoe", CurrentRole: "admin", Source: "direct",
+		SuggestedRole: "read", Evidence: "No commits in 30 days"}}
+
+	// The CSVs are named after the main report whatever its format
+	for _, format := range []string{formatCSV, formatJSON, formatXLSX} {
+		dir := t.TempDir()
+		g := &ghAPIClient{File: filepath.Join(dir, reportName("org-info.csv", format)), Format: format, SSO: true, Orphans: true, Roster: d.Roster,
+			LeastPrivilegeDays: 30}
+		err := writeFindingsCSVs(g, []ghOrgData{d})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		got := readTestCSV(t, filepath.Join(dir, "org-info-sso-unlinked.csv"))
+		want := [][]string{{"Org", "Login", "SSO Identity"}, {"acme", "jdoe", "NOT LINKED"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: writeFindingsCSVs wrote SSO unlinked members\n%q\nwant\n%q", format, got, want)
+		}
+
+		got = readTestCSV(t, filepath.Join(dir, "org-info-orphaned-repos.csv"))
+		want = [][]string{
+			{"Org", "Repo", "Reason", "Admins"},
+			{"acme", "api", "no-admins", ""},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: writeFindingsCSVs wrote orphans\n%q\nwant\n%q", format, got, want)
+		}
+
+		got = readTestCSV(t, filepath.Join(dir, "org-info-roster-exceptions.csv"))
+		want = [][]string{
+			{"Org", "Login", "Access", "Finding", "Status", "Manager"},
+			{"acme", "jdoe", "member", "not-on-roster", "", ""},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: writeFindingsCSVs wrote roster exceptions\n%q\nwant\n%q", format, got, want)
+		}
+
+		got = readTestCSV(t, filepath.Join(dir, "org-info-least-privilege.csv"))
+		want = [][]string{
+			{"Org", "Repo", "Login", "Current Role", "Source", "Suggested Role", "Last Activity", "Evidence"},
+			{"acme", "api", "jdoe", "admin", "direct", "read", "", "No commits in 30 days"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: writeFindingsCSVs wrote least-privilege recommendations\n%q\nwant\n%q", format, got, want)
+		}
+	}
+}
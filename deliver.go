@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Number of violations listed in the summary
+const topViolations = 5
+
+// Struct to hold where to deliver the report once it's written
+type delivery struct {
+	// SMTP server as host:port, the report is only emailed when this is set
+	SMTPHost string
+	SMTPFrom string
+	SMTPTo   []string
+	// Login for the SMTP server, the password is read from the SMTP_PASSWORD environmental variable
+	SMTPUser string
+	// Slack or Teams compatible incoming webhook URL the summary is posted to
+	Webhook string
+}
+
+// enabled returns true if the report is to be delivered anywhere
+func (d delivery) enabled() bool {
+	return len(d.SMTPHost) > 0 || len(d.Webhook) > 0
+}
+
+// deliverReport takes a pointer to ghAPIClient, the data collected for one or more orgs and the
+// previous snapshot (if any) and emails the report and/or posts a summary to a webhook
+func deliverReport(g *ghAPIClient, orgs []ghOrgData, prev string) error {
+	// Compare with the previous run when there is one
+	var changes *diffReport
+	if len(prev) > 0 {
+		old, err := loadJSONSnapshot(prev)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem reading previous snapshot %s was: %v", prev, err))
+		}
+		cur := snapshot{Repos: make(map[string]snapRepo), HasArchived: true}
+		for _, o := range orgs {
+			for k := range o.Repos {
+				addSnapRepo(cur, newJSONRepo(o, k))
+			}
+		}
+		d := diffSnapshots(old, cur)
+		changes = &d
+	}
+	subject, summary := reportSummary(g, orgs, changes)
+
+	if len(g.Delivery.SMTPHost) > 0 {
+		files := []string{g.File}
+		if len(g.ViolationsFile) > 0 {
+			files = append(files, g.ViolationsFile)
+		}
+		err := sendEmail(g.Delivery, subject, summary, files)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem emailing the report was: %v", err))
+		}
+		fmt.Printf("Emailed report to %s\n", strings.Join(g.Delivery.SMTPTo, ", "))
+	}
+
+	if len(g.Delivery.Webhook) > 0 {
+		err := postWebhook(g, g.Delivery.Webhook, "*"+subject+"*\n"+summary)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem posting to the webhook was: %v", err))
+		}
+		fmt.Println("Posted summary to webhook")
+	}
+
+	return nil
+}
+
+// reportSummary returns a subject line and a plain text summary of a run with counts, the most
+// severe policy violations and the changes since the previous run if changes isn't nil
+func reportSummary(g *ghAPIClient, orgs []ghOrgData, changes *diffReport) (string, string) {
+	var names []string
+	var repos, public, grants int
+	for _, o := range orgs {
+		names = append(names, o.Org)
+		for _, r := range o.Repos {
+			repos++
+			if strings.EqualFold(r.Visibility, "public") {
+				public++
+			}
+			grants += len(o.Admins[r.Name])
+		}
+	}
+	subject := "ghorg2csv report for " + strings.Join(names, ", ") + " on " + time.Now().Format("2006-01-02")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Repos: %d (%d public)\n", repos, public)
+	fmt.Fprintf(&b, "Admin grants: %d\n", grants)
+	if g.Orphans {
+		fmt.Fprintf(&b, "Orphaned repos: %d\n", len(orphanedRepos(orgs)))
+	}
+	if g.Roster != nil {
+		fmt.Fprintf(&b, "Accounts not on the roster, terminated or unmatched: %d\n", len(rosterExceptions(orgs)))
+	}
+
+	if g.Policy != nil {
+		bySeverity := make(map[string]int)
+		for _, v := range g.Violations {
+			bySeverity[v.Severity]++
+		}
+		var counts []string
+		for _, s := range severities {
+			if bySeverity[s] > 0 {
+				counts = append(counts, fmt.Sprintf("%d %s", bySeverity[s], s))
+			}
+		}
+		fmt.Fprintf(&b, "Policy violations: %d", len(g.Violations))
+		if len(counts) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(counts, ", "))
+		}
+		b.WriteString("\n")
+
+		// Violations are already sorted most severe first
+		for k, v := range g.Violations {
+			if k == topViolations {
+				fmt.Fprintf(&b, "  ... and %d more\n", len(g.Violations)-topViolations)
+				break
+			}
+			fmt.Fprintf(&b, "  [%s] %s/%s: %s\n", v.Severity, v.Org, v.Repo, v.Message)
+		}
+	}
+
+	if changes != nil {
+		b.WriteString("\nChanges since the last run\n")
+		fmt.Fprintf(&b, "  Repos added: %d, removed: %d\n", len(changes.AddedRepos), len(changes.RemovedRepos))
+		fmt.Fprintf(&b, "  Visibility changes: %d\n", len(changes.VisibilityChanges))
+		for _, v := range changes.VisibilityChanges {
+			fmt.Fprintf(&b, "    %s: %s -> %s\n", v.Repo, v.From, v.To)
+		}
+		fmt.Fprintf(&b, "  Admins added: %d, removed: %d\n", len(changes.AdminsAdded), len(changes.AdminsRemoved))
+	}
+
+	return subject, b.String()
+}
+
+// sendEmail emails a message with files attached over SMTP, authenticating when a user is set
+func sendEmail(d delivery, subject string, body string, files []string) error {
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\n", d.SMTPFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(d.SMTPTo, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	// Summary as the body of the email
+	pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return err
+	}
+	pw.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+
+	// Attach each file base64 encoded in lines of 76 characters
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		ct := mime.TypeByExtension(filepath.Ext(f))
+		if len(ct) == 0 {
+			ct = "application/octet-stream"
+		}
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {ct},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(f)})},
+		})
+		if err != nil {
+			return err
+		}
+		enc := base64.StdEncoding.EncodeToString(b)
+		for len(enc) > 76 {
+			pw.Write([]byte(enc[:76] + "\r\n"))
+			enc = enc[76:]
+		}
+		pw.Write([]byte(enc + "\r\n"))
+	}
+	err = mw.Close()
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if len(d.SMTPUser) > 0 {
+		host, _, err := net.SplitHostPort(d.SMTPHost)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", d.SMTPUser, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	return smtp.SendMail(d.SMTPHost, auth, d.SMTPFrom, d.SMTPTo, msg.Bytes())
+}
+
+// postWebhook posts a message to a Slack or Teams compatible incoming webhook, both of which
+// accept a JSON body with a text field
+func postWebhook(g *ghAPIClient, u string, text string) error {
+	b, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	resp, err := g.HttpClient.Post(u, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(fmt.Sprintf("Webhook response code was: %v", resp.StatusCode))
+	}
+
+	return nil
+}
+
+// latestSnapshot returns the newest snapshot in a directory, or an empty string if there are none
+func latestSnapshot(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) == 0 {
+		return ""
+	}
+	sort.Strings(files)
+
+	return files[len(files)-1]
+}
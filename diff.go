@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Struct to hold the parts of a repo compared between two reports
+type snapRepo struct {
+	FullName   string
+	Visibility string
+	Archived   bool
+	Admins     []string
+}
+
+// Struct to hold the repos from a previous report keyed by lower case full name.
+// HasArchived is false for CSVs without an Archived column
+type snapshot struct {
+	Repos       map[string]snapRepo
+	HasArchived bool
+}
+
+// Struct to hold a change to a single value of a repo
+type diffChange struct {
+	Repo string `json:"repo"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Struct to hold an admin added to or removed from a repo
+type diffAdmin struct {
+	Repo  string `json:"repo"`
+	Login string `json:"login"`
+}
+
+// Struct to hold the differences between two reports
+type diffReport struct {
+	Old               string       `json:"old"`
+	New               string       `json:"new"`
+	AddedRepos        []string     `json:"added_repos"`
+	RemovedRepos      []string     `json:"removed_repos"`
+	VisibilityChanges []diffChange `json:"visibility_changes"`
+	ArchivedChanges   []diffChange `json:"archived_changes"`
+	AdminsAdded       []diffAdmin  `json:"admins_added"`
+	AdminsRemoved     []diffAdmin  `json:"admins_removed"`
+	// Reports without an archived column, archived changes can't be found if there are any
+	ArchivedUnavailable []string `json:"archived_unavailable,omitempty"`
+}
+
+// Matches the login at the start of an entry in the Repo Admins CSV column, including
+// logins of bots e.g. dependabot[bot] and of enterprise managed users e.g. jdoe_acme
+var adminLogin = regexp.MustCompile(`^[A-Za-z0-9_-]+(?:\[bot\])?$`)
+
+// diffCommand handles the diff command which compares two previous reports and prints
+// the changes between them, exiting when done
+func diffCommand(args []string) {
+	fs := newFlagSet("diff")
+	var format, out, columns string
+	fs.StringVar(&format, "format", "text", "Provide the output format, either text or json")
+	fs.StringVar(&out, "out", "", "Provide a file to write the differences to instead of stdout")
+	fs.StringVar(&columns, "columns", "", "Provide the -columns used to write csv reports with custom header labels")
+	fs.Parse(args)
+
+	// Check arguments
+	if fs.NArg() != 2 {
+		fmt.Println("Error: diff needs exactly two reports to compare")
+		fs.Usage()
+		os.Exit(exitError)
+	}
+	if format != "text" && format != formatJSON {
+		fmt.Printf("ERROR: Unsupported diff format '%s', use text or json\n", format)
+		os.Exit(exitError)
+	}
+	cols := columnArgs(columns)
+
+	// Load both reports and compare them
+	oldSnap, err := loadSnapshot(fs.Arg(0), cols)
+	if err != nil {
+		fmt.Printf("Error reading %s was %+v\n", fs.Arg(0), err)
+		os.Exit(exitError)
+	}
+	newSnap, err := loadSnapshot(fs.Arg(1), cols)
+	if err != nil {
+		fmt.Printf("Error reading %s was %+v\n", fs.Arg(1), err)
+		os.Exit(exitError)
+	}
+	d := diffSnapshots(oldSnap, newSnap)
+	d.Old = fs.Arg(0)
+	d.New = fs.Arg(1)
+	if !oldSnap.HasArchived {
+		d.ArchivedUnavailable = append(d.ArchivedUnavailable, d.Old)
+	}
+	if !newSnap.HasArchived {
+		d.ArchivedUnavailable = append(d.ArchivedUnavailable, d.New)
+	}
+
+	// Write out the differences
+	w := io.Writer(os.Stdout)
+	if len(out) > 0 {
+		fi, err := os.Create(out)
+		if err != nil {
+			fmt.Printf("Error creating %s was %+v\n", out, err)
+			os.Exit(exitError)
+		}
+		defer fi.Close()
+		w = fi
+	}
+	if format == formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(d)
+	} else {
+		err = writeDiffText(w, d)
+	}
+	if err != nil {
+		fmt.Printf("Error writing differences was %+v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// loadSnapshot reads a previous report, picking the parser from the file extension. The
+// columns give the header labels of csv reports
+func loadSnapshot(f string, cols []csvColumn) (snapshot, error) {
+	switch strings.ToLower(filepath.Ext(f)) {
+	case ".json":
+		return loadJSONSnapshot(f)
+	case ".ndjson":
+		return loadNDJSONSnapshot(f)
+	case ".csv":
+		return loadCSVSnapshot(f, cols)
+	}
+
+	return snapshot{}, errors.New("Unsupported report type, use a .csv, .json or .ndjson file")
+}
+
+// loadJSONSnapshot reads a report written with -format json
+func loadJSONSnapshot(f string) (snapshot, error) {
+	s := snapshot{Repos: make(map[string]snapRepo), HasArchived: true}
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		return s, err
+	}
+	rpt := jsonReport{}
+	err = json.Unmarshal(b, &rpt)
+	if err != nil {
+		return s, err
+	}
+	for _, o := range rpt.Orgs {
+		for _, r := range o.Repos {
+			addSnapRepo(s, r)
+		}
+	}
+
+	return s, nil
+}
+
+// loadNDJSONSnapshot reads a report written with -format ndjson
+func loadNDJSONSnapshot(f string) (snapshot, error) {
+	s := snapshot{Repos: make(map[string]snapRepo), HasArchived: true}
+	fi, err := os.Open(f)
+	if err != nil {
+		return s, err
+	}
+	defer fi.Close()
+
+	scan := bufio.NewScanner(fi)
+	scan.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scan.Scan() {
+		if len(strings.TrimSpace(scan.Text())) == 0 {
+			continue
+		}
+		r := ndjsonRepo{}
+		err = json.Unmarshal(scan.Bytes(), &r)
+		if err != nil {
+			return s, err
+		}
+		addSnapRepo(s, r.jsonRepo)
+	}
+
+	return s, scan.Err()
+}
+
+// addSnapRepo adds a repo from a JSON report to the snapshot
+func addSnapRepo(s snapshot, r jsonRepo) {
+	sr := snapRepo{FullName: r.FullName, Visibility: r.Visibility, Archived: r.Archived}
+	for _, a := range r.Admins {
+		sr.Admins = append(sr.Admins, a.Login)
+	}
+	s.Repos[strings.ToLower(r.FullName)] = sr
+}
+
+// loadCSVSnapshot reads a report written with -format csv in either layout. The Full Name,
+// Visibility, Archived and Repo Admins columns of the wide layout are found by their header
+// labels in the columns provided, which are the default columns unless -columns was used.
+// The long layout is found by its Login and Permission columns
+func loadCSVSnapshot(f string, cols []csvColumn) (snapshot, error) {
+	s := snapshot{Repos: make(map[string]snapRepo)}
+	fi, err := os.Open(f)
+	if err != nil {
+		return s, err
+	}
+	defer fi.Close()
+
+	rows, err := csv.NewReader(fi).ReadAll()
+	if err != nil {
+		return s, err
+	}
+	if len(rows) == 0 {
+		return s, errors.New("CSV is empty")
+	}
+
+	// Find the columns by header
+	col := make(map[string]int)
+	for k, v := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(v))] = k
+	}
+	labels := map[string]string{
+		"full_name":  "full name",
+		"visibility": "visibility",
+		"archived":   "archived",
+		"admins":     "repo admins",
+	}
+	for _, c := range cols {
+		if _, exists := labels[c.Key]; exists {
+			labels[c.Key] = strings.ToLower(c.Label)
+		}
+	}
+	nameCol, exists := col[labels["full_name"]]
+	if !exists {
+		return s, errors.New(fmt.Sprintf("CSV has no '%s' column, use -columns if the report was written with custom labels", labels["full_name"]))
+	}
+	visCol, hasVis := col[labels["visibility"]]
+	archCol, hasArch := col[labels["archived"]]
+	admCol, hasAdm := col[labels["admins"]]
+	loginCol, hasLogin := col["login"]
+	permCol, hasPerm := col["permission"]
+	long := hasLogin && hasPerm
+	s.HasArchived = hasArch
+
+	for k, row := range rows[1:] {
+		key := strings.ToLower(row[nameCol])
+		sr, seen := s.Repos[key]
+		if !seen {
+			sr.FullName = row[nameCol]
+			if hasVis {
+				sr.Visibility = row[visCol]
+			}
+			if hasArch {
+				sr.Archived, _ = strconv.ParseBool(row[archCol])
+			}
+			if hasAdm && !long {
+				sr.Admins, err = parseAdmins(row[admCol])
+				if err != nil {
+					return s, errors.New(fmt.Sprintf("Problem reading the admins on line %d was: %v", k+2, err))
+				}
+			}
+		}
+		if long && strings.Compare(row[permCol], "admin") == 0 {
+			sr.Admins = append(sr.Admins, row[loginCol])
+		}
+		s.Repos[key] = sr
+	}
+
+	return s, nil
+}
+
+// parseAdmins takes the Repo Admins column of the wide CSV and returns the admin logins.
+// Admins are separated by ", " each optionally followed by their details in brackets e.g.
+// "jdoe (Jane Doe - jane@example.com), dependabot[bot]". Details can contain ", " and
+// brackets of their own so an entry runs on until its details are closed
+func parseAdmins(cell string) ([]string, error) {
+	var admins []string
+	parts := strings.Split(strings.TrimSpace(cell), ", ")
+	for i := 0; i < len(parts); i++ {
+		entry := parts[i]
+		if len(entry) == 0 {
+			continue
+		}
+		for strings.Contains(entry, " (") && !strings.HasSuffix(entry, ")") && i+1 < len(parts) {
+			i++
+			entry += ", " + parts[i]
+		}
+
+		login := entry
+		if j := strings.Index(entry, " ("); j >= 0 {
+			if !strings.HasSuffix(entry, ")") {
+				return nil, errors.New(fmt.Sprintf("Admin details aren't closed in '%s'", entry))
+			}
+			login = entry[:j]
+		}
+		if !adminLogin.MatchString(login) {
+			return nil, errors.New(fmt.Sprintf("Unable to parse admin entry '%s'", entry))
+		}
+		admins = append(admins, login)
+	}
+
+	return admins, nil
+}
+
+// diffSnapshots compares two snapshots and returns the differences sorted by repo
+func diffSnapshots(o snapshot, n snapshot) diffReport {
+	// Use empty lists rather than null in the JSON output
+	d := diffReport{
+		AddedRepos:        []string{},
+		RemovedRepos:      []string{},
+		VisibilityChanges: []diffChange{},
+		ArchivedChanges:   []diffChange{},
+		AdminsAdded:       []diffAdmin{},
+		AdminsRemoved:     []diffAdmin{},
+	}
+
+	// Repos that are new or changed
+	for k, nr := range n.Repos {
+		or, exists := o.Repos[k]
+		if !exists {
+			d.AddedRepos = append(d.AddedRepos, nr.FullName)
+			for _, a := range nr.Admins {
+				d.AdminsAdded = append(d.AdminsAdded, diffAdmin{nr.FullName, a})
+			}
+			continue
+		}
+		if len(or.Visibility) > 0 && len(nr.Visibility) > 0 && !strings.EqualFold(or.Visibility, nr.Visibility) {
+			d.VisibilityChanges = append(d.VisibilityChanges, diffChange{nr.FullName, or.Visibility, nr.Visibility})
+		}
+		if o.HasArchived && n.HasArchived && or.Archived != nr.Archived {
+			d.ArchivedChanges = append(d.ArchivedChanges,
+				diffChange{nr.FullName, strconv.FormatBool(or.Archived), strconv.FormatBool(nr.Archived)})
+		}
+		for _, a := range nr.Admins {
+			if !containsFold(or.Admins, a) {
+				d.AdminsAdded = append(d.AdminsAdded, diffAdmin{nr.FullName, a})
+			}
+		}
+		for _, a := range or.Admins {
+			if !containsFold(nr.Admins, a) {
+				d.AdminsRemoved = append(d.AdminsRemoved, diffAdmin{nr.FullName, a})
+			}
+		}
+	}
+
+	// Repos that are gone
+	for k, or := range o.Repos {
+		if _, exists := n.Repos[k]; !exists {
+			d.RemovedRepos = append(d.RemovedRepos, or.FullName)
+			for _, a := range or.Admins {
+				d.AdminsRemoved = append(d.AdminsRemoved, diffAdmin{or.FullName, a})
+			}
+		}
+	}
+
+	// Sort everything for stable output
+	sort.Strings(d.AddedRepos)
+	sort.Strings(d.RemovedRepos)
+	sortChanges(d.VisibilityChanges)
+	sortChanges(d.ArchivedChanges)
+	sortAdmins(d.AdminsAdded)
+	sortAdmins(d.AdminsRemoved)
+
+	return d
+}
+
+// writeDiffText writes the differences between two reports in a human readable form
+func writeDiffText(w io.Writer, d diffReport) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "Changes from %s to %s\n", d.Old, d.New)
+
+	fmt.Fprintf(b, "\nRepos added (%d)\n", len(d.AddedRepos))
+	for _, v := range d.AddedRepos {
+		fmt.Fprintf(b, "  + %s\n", v)
+	}
+	fmt.Fprintf(b, "\nRepos removed (%d)\n", len(d.RemovedRepos))
+	for _, v := range d.RemovedRepos {
+		fmt.Fprintf(b, "  - %s\n", v)
+	}
+	fmt.Fprintf(b, "\nVisibility changes (%d)\n", len(d.VisibilityChanges))
+	for _, v := range d.VisibilityChanges {
+		flag := ""
+		if strings.EqualFold(v.To, "public") {
+			flag = "  <-- now public"
+		}
+		fmt.Fprintf(b, "  * %s: %s -> %s%s\n", v.Repo, v.From, v.To, flag)
+	}
+	if len(d.ArchivedUnavailable) > 0 {
+		fmt.Fprintf(b, "\nArchived changes: not available (no archived column in %s)\n", strings.Join(d.ArchivedUnavailable, " and "))
+	} else {
+		fmt.Fprintf(b, "\nArchived changes (%d)\n", len(d.ArchivedChanges))
+		for _, v := range d.ArchivedChanges {
+			fmt.Fprintf(b, "  * %s: archived %s -> %s\n", v.Repo, v.From, v.To)
+		}
+	}
+	fmt.Fprintf(b, "\nAdmins added (%d)\n", len(d.AdminsAdded))
+	for _, v := range d.AdminsAdded {
+		fmt.Fprintf(b, "  + %s on %s\n", v.Login, v.Repo)
+	}
+	fmt.Fprintf(b, "\nAdmins removed (%d)\n", len(d.AdminsRemoved))
+	for _, v := range d.AdminsRemoved {
+		fmt.Fprintf(b, "  - %s on %s\n", v.Login, v.Repo)
+	}
+
+	return b.Flush()
+}
+
+// containsFold returns true if the string s is in the slice l, ignoring case
+func containsFold(l []string, s string) bool {
+	for _, v := range l {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortChanges sorts changes by repo
+func sortChanges(c []diffChange) {
+	sort.Slice(c, func(i, j int) bool { return c[i].Repo < c[j].Repo })
+}
+
+// sortAdmins sorts admin changes by repo then login
+func sortAdmins(a []diffAdmin) {
+	sort.Slice(a, func(i, j int) bool {
+		if a[i].Repo == a[j].Repo {
+			return a[i].Login < a[j].Login
+		}
+		return a[i].Repo < a[j].Repo
+	})
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAdmins(t *testing.T) {
+	tests := []struct {
+		name    string
+		cell    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty cell", cell: "", want: nil},
+		{name: "blank cell", cell: "  ", want: nil},
+		{name: "single login", cell: "jdoe", want: []string{"jdoe"}},
+		{name: "logins without details", cell: "jdoe, asmith", want: []string{"jdoe", "asmith"}},
+		{
+			name: "details",
+			cell: "jdoe (Jane Doe - jane@example.com [profile]), asmith (Al Smith)",
+			want: []string{"jdoe", "asmith"},
+		},
+		{
+			name: "bot logins",
+			cell: "dependabot[bot], jdoe (Jane Doe), renovate[bot]",
+			want: []string{"dependabot[bot]", "jdoe", "renovate[bot]"},
+		},
+		{
+			name: "enterprise managed user",
+			cell: "jdoe_acme (Jane Doe)",
+			want: []string{"jdoe_acme"},
+		},
+		{
+			name: "name containing parentheses",
+			cell: "jdoe (Jane (JD) Doe - jane@example.com), asmith (Al Smith (Contractor))",
+			want: []string{"jdoe", "asmith"},
+		},
+		{
+			name: "name containing a comma",
+			cell: "jdoe (Doe, Jane - jane@example.com), asmith",
+			want: []string{"jdoe", "asmith"},
+		},
+		{
+			name: "name with an unbalanced bracket",
+			cell: "jdoe (Jane :) Doe), asmith",
+			want: []string{"jdoe", "asmith"},
+		},
+		{
+			name: "all details",
+			cell: "jdoe (Jane Doe - jane@example.com [commits] - SSO: NOT LINKED - Manager: boss@example.com)",
+			want: []string{"jdoe"},
+		},
+		{name: "invalid login", cell: "jdoe, not a login", wantErr: true},
+		{name: "details not closed", cell: "jdoe (Jane Doe, asmith", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseAdmins(tc.cell)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseAdmins(%q) returned error %v, want error %v", tc.cell, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseAdmins(%q) = %q, want %q", tc.cell, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseAdminsWrittenByListAdmins(t *testing.T) {
+	lu := map[string]ghNameDetail{
+		"jdoe":            {Name: "Doe, Jane (JD)", Email: "jane@example.com", EmailSource: "profile", SSOStatus: ssoLinked, SSONameID: "jane@example.com"},
+		"dependabot[bot]": {},
+		"asmith":          {Name: "Al Smith", Manager: "boss@example.com", SSOStatus: ssoUnlinked},
+	}
+	admins := testOrgData(t, "acme", `[]`, map[string]string{
+		"api": `[{"login":"jdoe"},{"login":"dependabot[bot]"},{"login":"asmith"}]`,
+	}).Collabs
+
+	cell := strings.TrimRight(listAdmins("api", admins, lu), ", ")
+	got, err := parseAdmins(cell)
+	if err != nil {
+		t.Fatalf("parseAdmins(%q) returned %v", cell, err)
+	}
+	want := []string{"jdoe", "dependabot[bot]", "asmith"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAdmins(%q) = %q, want %q", cell, got, want)
+	}
+}
+
+func TestLoadCSVSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns string
+		csv     string
+		want    map[string]snapRepo
+		wantErr bool
+	}{
+		{
+			name: "default labels",
+			csv: "Org,Full Name,Visibility,Last Update,Repo Admins\n" +
+				"acme,acme/api,private,2022-06-13T07:59:05Z,\"jdoe (Jane Doe), dependabot[bot]\"\n" +
+				"acme,acme/web,public,2022-06-13T07:59:05Z,\n",
+			want: map[string]snapRepo{
+				"acme/api": {FullName: "acme/api", Visibility: "private", Admins: []string{"jdoe", "dependabot[bot]"}},
+				"acme/web": {FullName: "acme/web", Visibility: "public"},
+			},
+		},
+		{
+			name:    "custom labels",
+			columns: "full_name:Repo,visibility:Vis,archived:Is Archived,admins:Owners",
+			csv:     "Repo,Vis,Is Archived,Owners\nacme/api,internal,true,jdoe\n",
+			want: map[string]snapRepo{
+				"acme/api": {FullName: "acme/api", Visibility: "internal", Archived: true, Admins: []string{"jdoe"}},
+			},
+		},
+		{
+			name: "long layout",
+			csv: "Full Name,Login,User Name,Email,Permission,Source\n" +
+				"acme/api,jdoe,,,admin,direct\nacme/api,asmith,,,write,direct\nacme/api,dependabot[bot],,,admin,direct\n" +
+				"acme/orphan,,,,,\n",
+			want: map[string]snapRepo{
+				"acme/api":    {FullName: "acme/api", Admins: []string{"jdoe", "dependabot[bot]"}},
+				"acme/orphan": {FullName: "acme/orphan"},
+			},
+		},
+		{
+			name:    "custom labels without -columns",
+			csv:     "Repo,Owners\nacme/api,jdoe\n",
+			wantErr: true,
+		},
+		{
+			name:    "admin that doesn't parse",
+			csv:     "Full Name,Repo Admins\nacme/api,\"jdoe, not a login\"\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := filepath.Join(t.TempDir(), "report.csv")
+			err := ioutil.WriteFile(f, []byte(tc.csv), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cols, err := parseColumns(tc.columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadSnapshot(f, cols)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("loadSnapshot returned error %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Repos, tc.want) {
+				t.Errorf("loadSnapshot = %+v, want %+v", got.Repos, tc.want)
+			}
+		})
+	}
+}
+
+// emptyLists returns the differences with empty lists in place of nil, as diffSnapshots returns them
+func emptyLists(d diffReport) diffReport {
+	if d.AddedRepos == nil {
+		d.AddedRepos = []string{}
+	}
+	if d.RemovedRepos == nil {
+		d.RemovedRepos = []string{}
+	}
+	if d.VisibilityChanges == nil {
+		d.VisibilityChanges = []diffChange{}
+	}
+	if d.ArchivedChanges == nil {
+		d.ArchivedChanges = []diffChange{}
+	}
+	if d.AdminsAdded == nil {
+		d.AdminsAdded = []diffAdmin{}
+	}
+	if d.AdminsRemoved == nil {
+		d.AdminsRemoved = []diffAdmin{}
+	}
+
+	return d
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	repo := func(n string, vis string, archived bool, admins ...string) snapRepo {
+		return snapRepo{FullName: n, Visibility: vis, Archived: archived, Admins: admins}
+	}
+	snap := func(archived bool, repos ...snapRepo) snapshot {
+		s := snapshot{Repos: make(map[string]snapRepo), HasArchived: archived}
+		for _, r := range repos {
+			s.Repos[strings.ToLower(r.FullName)] = r
+		}
+		return s
+	}
+
+	tests := []struct {
+		name string
+		old  snapshot
+		new  snapshot
+		want diffReport
+	}{
+		{
+			name: "no changes",
+			old:  snap(true, repo("acme/api", "private", false, "jdoe")),
+			new:  snap(true, repo("acme/api", "private", false, "JDoe")),
+			want: diffReport{},
+		},
+		{
+			name: "repos added and removed",
+			old:  snap(true, repo("acme/old", "private", false, "jdoe")),
+			new:  snap(true, repo("acme/web", "public", false, "asmith"), repo("acme/api", "private", false)),
+			want: diffReport{
+				AddedRepos:    []string{"acme/api", "acme/web"},
+				RemovedRepos:  []string{"acme/old"},
+				AdminsAdded:   []diffAdmin{{"acme/web", "asmith"}},
+				AdminsRemoved: []diffAdmin{{"acme/old", "jdoe"}},
+			},
+		},
+		{
+			name: "values and admins changed",
+			old:  snap(true, repo("acme/api", "private", false, "jdoe", "asmith")),
+			new:  snap(true, repo("acme/api", "public", true, "jdoe", "carol", "dependabot[bot]")),
+			want: diffReport{
+				VisibilityChanges: []diffChange{{"acme/api", "private", "public"}},
+				ArchivedChanges:   []diffChange{{"acme/api", "false", "true"}},
+				AdminsAdded:       []diffAdmin{{"acme/api", "carol"}, {"acme/api", "dependabot[bot]"}},
+				AdminsRemoved:     []diffAdmin{{"acme/api", "asmith"}},
+			},
+		},
+		{
+			name: "values missing from a report",
+			old:  snap(false, repo("acme/api", "", false)),
+			new:  snap(true, repo("acme/api", "public", true)),
+			want: diffReport{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := diffSnapshots(tc.old, tc.new)
+			want := emptyLists(tc.want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("diffSnapshots =\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteDiffTextArchived(t *testing.T) {
+	tests := []struct {
+		name string
+		d    diffReport
+		want string
+	}{
+		{
+			name: "archived columns in both reports",
+			d:    diffReport{Old: "old.csv", New: "new.json", ArchivedChanges: []diffChange{{"acme/api", "false", "true"}}},
+			want: "\nArchived changes (1)\n  * acme/api: archived false -> true\n",
+		},
+		{
+			name: "no archived column in the old report",
+			d:    diffReport{Old: "old.csv", New: "new.json", ArchivedUnavailable: []string{"old.csv"}},
+			want: "\nArchived changes: not available (no archived column in old.csv)\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			err := writeDiffText(&b, tc.d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(b.String(), tc.want) {
+				t.Errorf("writeDiffText wrote\n%s\nwant it to contain\n%s", b.String(), tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// Struct to hold which repos to leave out of the report
+type repoFilter struct {
+	// Only keep repos with one of these visibilities e.g. public, empty to keep all
+	Visibility []string
+	// Leave out archived repos and forks
+	ExcludeArchived bool
+	ExcludeForks    bool
+	// Lower case patterns of repo names e.g. api-*, only repos matching one of Include are
+	// kept and those matching one of Exclude are left out
+	Include []string
+	Exclude []string
+}
+
+// enabled returns true if the filter leaves out any repos
+func (f repoFilter) enabled() bool {
+	return len(f.Visibility) > 0 || f.ExcludeArchived || f.ExcludeForks || len(f.Include) > 0 || len(f.Exclude) > 0
+}
+
+// filterRepos takes a filter and the repos of an org and returns the repos the filter keeps
+func filterRepos(f repoFilter, r ghRepoInfo) ghRepoInfo {
+	kept := ghRepoInfo{}
+	for k := range r {
+		if len(f.Visibility) > 0 && !containsString(f.Visibility, strings.ToLower(r[k].Visibility)) {
+			continue
+		}
+		if (f.ExcludeArchived && r[k].Archived) || (f.ExcludeForks && r[k].Fork) {
+			continue
+		}
+		if len(f.Include) > 0 && !matchesAny(f.Include, r[k].Name) {
+			continue
+		}
+		if matchesAny(f.Exclude, r[k].Name) {
+			continue
+		}
+		kept = append(kept, r[k])
+	}
+
+	return kept
+}
+
+// matchesAny returns true if a repo name matches one of the lower case patterns, ignoring case
+func matchesAny(patterns []string, n string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, strings.ToLower(n)); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterRepos(t *testing.T) {
+	repos := ghRepoInfo{
+		{Name: "api", Visibility: "public"},
+		{Name: "API-v2", Visibility: "Internal"},
+		{Name: "web", Visibility: "private", Archived: true},
+		{Name: "sandbox-jdoe", Visibility: "private", Fork: true},
+	}
+
+	tests := []struct {
+		name   string
+		filter repoFilter
+		want   []string
+	}{
+		{name: "no filter", want: []string{"api", "API-v2", "web", "sandbox-jdoe"}},
+		{name: "visibility", filter: repoFilter{Visibility: []string{"public", "internal"}}, want: []string{"api", "API-v2"}},
+		{name: "exclude archived", filter: repoFilter{ExcludeArchived: true}, want: []string{"api", "API-v2", "sandbox-jdoe"}},
+		{name: "exclude forks", filter: repoFilter{ExcludeForks: true}, want: []string{"api", "API-v2", "web"}},
+		{name: "include ignoring case", filter: repoFilter{Include: []string{"api*"}}, want: []string{"api", "API-v2"}},
+		{name: "exclude", filter: repoFilter{Exclude: []string{"sandbox-*", "web"}}, want: []string{"api", "API-v2"}},
+		{
+			name:   "exclude over include",
+			filter: repoFilter{Include: []string{"api*", "web"}, Exclude: []string{"*-v2"}},
+			want:   []string{"api", "web"},
+		},
+		{name: "nothing kept", filter: repoFilter{Include: []string{"docs"}}, want: nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, r := range filterRepos(tc.filter, repos) {
+				got = append(got, r.Name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("filterRepos kept %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		n        string
+		want     bool
+	}{
+		{nil, "api", false},
+		{[]string{"api"}, "API", true},
+		{[]string{"api-*"}, "api-gateway", true},
+		{[]string{"api-*"}, "api", false},
+		{[]string{"web", "?pi"}, "Api", true},
+		{[]string{"[a-c]*"}, "docs", false},
+		{[]string{"[bad"}, "api", false},
+	}
+	for _, tc := range tests {
+		if got := matchesAny(tc.patterns, tc.n); got != tc.want {
+			t.Errorf("matchesAny(%q, %q) = %v, want %v", tc.patterns, tc.n, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Sources of a user's access to a repo
+const (
+	accessOutside   = "outside-collaborator"
+	accessDirect    = "direct"
+	accessInherited = "team-or-org"
+)
+
+// collectAccessSources takes a pointer to ghAPIClient and ghOrgData and gathers what's needed to
+// label where each collaborator's access comes from: the org's outside collaborators and the
+// collaborators added directly to each repo. Anyone else has access through a team or the org
+func collectAccessSources(g *ghAPIClient, d *ghOrgData) error {
+	// Outside collaborators only exist for organizations
+	d.Outside = make(map[string]bool)
+	if g.OwnerType == ownerOrg {
+		outside := ghMembers{}
+		err := getOutsideCollabs(g, &outside)
+		if err != nil {
+			return err
+		}
+		for _, v := range outside {
+			d.Outside[strings.ToLower(v.Login)] = true
+		}
+	}
+
+	// Direct collaborators for each repo
+	d.Direct = make(map[string]map[string]bool)
+	for _, r := range d.Repos {
+		direct := ghCollaborators{}
+		err := getDirectCollabs(g, r.Name, &direct)
+		if err != nil {
+			return err
+		}
+		d.Direct[r.Name] = make(map[string]bool)
+		for _, v := range direct {
+			d.Direct[r.Name][strings.ToLower(v.Login)] = true
+		}
+	}
+
+	return nil
+}
+
+// collectOwners takes a pointer to ghAPIClient and ghOrgData and gathers the owners of the org
+func collectOwners(g *ghAPIClient, d *ghOrgData) error {
+	owners, err := ownerLogins(g)
+	if err != nil {
+		return err
+	}
+	d.Owners = owners
+
+	return nil
+}
+
+// ownerLogins takes a pointer to ghAPIClient and returns the lower case logins of the owners
+// of the current org
+func ownerLogins(g *ghAPIClient) (map[string]bool, error) {
+	owners := ghMembers{}
+	err := getMembers(g, "admin", &owners)
+	if err != nil {
+		return nil, err
+	}
+	logins := make(map[string]bool)
+	for _, v := range owners {
+		logins[strings.ToLower(v.Login)] = true
+	}
+
+	return logins, nil
+}
+
+// accessSource returns where a user's access to a repo comes from, or an empty string
+// if access sources weren't collected
+func accessSource(o ghOrgData, repo string, l string) string {
+	if o.Direct == nil {
+		return ""
+	}
+	if o.Outside[strings.ToLower(l)] {
+		return accessOutside
+	}
+	if o.Direct[repo][strings.ToLower(l)] {
+		return accessDirect
+	}
+
+	return accessInherited
+}
+
+// getOutsideCollabs takes pointers to ghAPIClient and ghMembers and retrieves all the
+// outside collaborators of the current org
+// see https://docs.github.com/en/rest/orgs/outside-collaborators#list-outside-collaborators-for-an-organization
+func getOutsideCollabs(g *ghAPIClient, m *ghMembers) error {
+	return getAllPages(g, "/orgs/"+g.Org+"/outside_collaborators", "Outside collaborators", func(page []byte) error {
+		tempMembers := ghMembers{}
+		err := json.Unmarshal(page, &tempMembers)
+		*m = append(*m, tempMembers...)
+		return err
+	})
+}
+
+// getDirectCollabs takes a pointer to ghAPIClient, a repo name and a pointer to ghCollaborators
+// and retrieves the collaborators added directly to the repo rather than through a team or the org
+// see https://docs.github.com/en/rest/collaborators/collaborators#list-repository-collaborators
+func getDirectCollabs(g *ghAPIClient, repo string, c *ghCollaborators) error {
+	u := "/repos/" + g.Org + "/" + repo + "/collaborators?affiliation=direct"
+	return getAllPages(g, u, "Repo direct collaborators", func(page []byte) error {
+		tempCollab := ghCollaborators{}
+		err := json.Unmarshal(page, &tempCollab)
+		*c = append(*c, tempCollab...)
+		return err
+	})
+}
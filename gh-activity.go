@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Repo events which show a user writing to a repo
+var writeEvents = []string{"PushEvent", "PullRequestEvent", "CreateEvent", "DeleteEvent", "ReleaseEvent"}
+
+// Repo events which show a user triaging without writing to a repo
+var triageEvents = []string{"PullRequestReviewEvent", "PullRequestReviewCommentEvent", "IssuesEvent", "IssueCommentEvent"}
+
+// Github keeps at most 300 events per repo, in pages of 100, from the last 90 days
+const (
+	maxEventPages = 3
+	maxEventDays  = 90
+)
+
+// collectActivity takes a pointer to ghAPIClient and ghOrgData and looks for recent commit, pull
+// request and issue activity by each repo admin. Admins with no write activity in the last
+// LeastPrivilegeDays field of ghAPIClient days are recommended a lower role. Org owners, bots,
+// admins through a team or the org and archived repos are skipped
+func collectActivity(g *ghAPIClient, d *ghOrgData) error {
+	since := time.Now().AddDate(0, 0, -g.LeastPrivilegeDays)
+	d.Recommendations = []ghRecommendation{}
+
+	// Org owners are admins of every repo so can't be downgraded repo by repo
+	owners := d.Owners
+	if owners == nil && g.OwnerType == ownerOrg {
+		var err error
+		owners, err = ownerLogins(g)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, r := range d.Repos {
+		if r.Archived || len(d.Admins[r.Name]) == 0 {
+			continue
+		}
+
+		// Events cover pull requests, reviews and issues for the last 90 days at most
+		events := ghEvents{}
+		err := getRepoEvents(g, r.Name, &events)
+		if err != nil {
+			return err
+		}
+		checked := eventsSince(events, since)
+
+		for _, a := range d.Admins[r.Name] {
+			if isBot(a.Login, a.Type) || owners[strings.ToLower(a.Login)] {
+				continue
+			}
+			// Inherited access can only be lowered on the team or org granting it
+			if accessSource(*d, r.Name, a.Login) == accessInherited {
+				continue
+			}
+
+			// Most recent write and triage events by the admin
+			var lastWrite, lastTriage ghEvent
+			for _, e := range events {
+				if !strings.EqualFold(e.Actor.Login, a.Login) || e.CreatedAt.Before(since) {
+					continue
+				}
+				if containsString(writeEvents, e.Type) && e.CreatedAt.After(lastWrite.CreatedAt) {
+					lastWrite = e
+				}
+				if containsString(triageEvents, e.Type) && e.CreatedAt.After(lastTriage.CreatedAt) {
+					lastTriage = e
+				}
+			}
+			if len(lastWrite.Type) > 0 {
+				continue
+			}
+
+			// Commits reach back further than events, empty repos have none
+			commits := ghCommits{}
+			if r.Size > 0 {
+				err = getJSON(g, "/repos/"+g.Org+"/"+r.Name+"/commits?per_page=1&author="+url.QueryEscape(a.Login)+
+					"&since="+since.UTC().Format(time.RFC3339), "Repo commits", &commits)
+				if err != nil {
+					return err
+				}
+			}
+			if len(commits) > 0 {
+				continue
+			}
+
+			rec := ghRecommendation{
+				Org:           d.Org,
+				Repo:          r.Name,
+				Login:         a.Login,
+				CurrentRole:   a.RoleName,
+				Source:        accessSource(*d, r.Name, a.Login),
+				SuggestedRole: "read",
+				Evidence:      fmt.Sprintf("No commits, pushes or pull requests in the last %d days", g.LeastPrivilegeDays),
+			}
+			if checked.After(since) {
+				rec.Evidence = fmt.Sprintf("No commits in the last %d days and no pushes or pull requests since %s, "+
+					"the oldest repo event Github keeps", g.LeastPrivilegeDays, checked.Format("2006-01-02"))
+			}
+			if len(lastTriage.Type) > 0 {
+				rec.SuggestedRole = "triage"
+				rec.LastActivity = lastTriage.CreatedAt.Format("2006-01-02")
+				rec.Evidence += "; last " + lastTriage.Type + " on " + rec.LastActivity
+			}
+			d.Recommendations = append(d.Recommendations, rec)
+		}
+	}
+	resetMeta(g)
+
+	return nil
+}
+
+// eventsSince takes the events of a repo and the start of the window looked at and returns
+// when the events actually start, as Github only keeps the last 300 events from 90 days
+func eventsSince(events ghEvents, since time.Time) time.Time {
+	checked := since
+	if oldest := time.Now().AddDate(0, 0, -maxEventDays); oldest.After(checked) {
+		checked = oldest
+	}
+	// Events are newest first so when the limit is reached the last is the oldest kept
+	if len(events) >= maxEventPages*100 {
+		if last := events[len(events)-1].CreatedAt; last.After(checked) {
+			checked = last
+		}
+	}
+
+	return checked
+}
+
+// getRepoEvents takes a pointer to ghAPIClient, a repo name and a pointer to ghEvents and
+// retrieves the recent events of the repo
+// see https://docs.github.com/en/rest/activity/events#list-repository-events
+func getRepoEvents(g *ghAPIClient, repo string, e *ghEvents) error {
+	pages := 0
+	return getAllPages(g, "/repos/"+g.Org+"/"+repo+"/events?per_page=100", "Repo events", func(page []byte) error {
+		tempEvents := ghEvents{}
+		err := json.Unmarshal(page, &tempEvents)
+		*e = append(*e, tempEvents...)
+		pages++
+		if err == nil && pages == maxEventPages {
+			return errLastPage
+		}
+		return err
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCollectActivity(t *testing.T) {
+	recent := time.Now().AddDate(0, 0, -10).UTC()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/acme/api/events":
+			fmt.Fprintf(w, `[{"type":"PushEvent","actor":{"login":"pusher"},"created_at":"%[1]s"},`+
+				`{"type":"PullRequestReviewEvent","actor":{"login":"reviewer"},"created_at":"%[1]s"}]`, recent.Format(time.RFC3339))
+		case r.URL.Path == "/repos/acme/api/commits" && r.URL.Query().Get("author") == "committer":
+			fmt.Fprint(w, `[{"sha":"abc"}]`)
+		case r.URL.Path == "/repos/acme/api/commits":
+			fmt.Fprint(w, `[]`)
+		default:
+			t.Errorf("unexpected request for %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		days     int
+		evidence string
+	}{
+		{name: "within the events kept", days: 30, evidence: "No commits, pushes or pull requests in the last 30 days"},
+		{
+			name: "beyond the events kept",
+			days: 180,
+			evidence: "No commits in the last 180 days and no pushes or pull requests since " + time.Now().AddDate(0, 0, -maxEventDays).Format("2006-01-02") +
+				", the oldest repo event Github keeps",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := testClient(t, srv)
+			g.LeastPrivilegeDays = tc.days
+			d := testOrgData(t, "acme", `[{"name":"api","size":10},{"name":"old","archived":true}]`, nil)
+			admins := `[{"login":"pusher","role_name":"admin"},{"login":"reviewer","role_name":"admin"},` +
+				`{"login":"committer","role_name":"admin"},{"login":"idle","role_name":"admin"},` +
+				`{"login":"teamadmin","role_name":"admin"},{"login":"owner","role_name":"admin"},` +
+				`{"login":"dependabot[bot]","type":"Bot","role_name":"admin"}]`
+			d.Admins = testOrgData(t, "acme", `[]`, map[string]string{"api": admins, "old": admins}).Collabs
+			d.Owners = map[string]bool{"owner": true}
+			d.Direct = map[string]map[string]bool{"api": {"pusher": true, "reviewer": true, "committer": true, "owner": true}}
+			d.Outside = map[string]bool{"idle": true}
+
+			err := collectActivity(g, &d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			day := recent.Format("2006-01-02")
+			want := []ghRecommendation{
+				{Org: "acme", Repo: "api", Login: "reviewer", CurrentRole: "admin", Source: accessDirect, SuggestedRole: "triage",
+					LastActivity: day, Evidence: tc.evidence + "; last PullRequestReviewEvent on " + day},
+				{Org: "acme", Repo: "api", Login: "idle", CurrentRole: "admin", Source: accessOutside, SuggestedRole: "read",
+					Evidence: tc.evidence},
+			}
+			if !reflect.DeepEqual(d.Recommendations, want) {
+				t.Errorf("collectActivity recommended\n%+v\nwant\n%+v", d.Recommendations, want)
+			}
+		})
+	}
+}
+
+func TestEventsSince(t *testing.T) {
+	now := time.Now()
+	since := now.AddDate(0, 0, -30)
+	full := make(ghEvents, maxEventPages*100)
+	for k := range full {
+		full[k].CreatedAt = now.Add(-time.Duration(k) * time.Hour)
+	}
+
+	tests := []struct {
+		name   string
+		events ghEvents
+		since  time.Time
+		want   time.Time
+	}{
+		{name: "within 90 days", since: since, want: since},
+		{name: "beyond 90 days", since: now.AddDate(0, 0, -180), want: now.AddDate(0, 0, -maxEventDays)},
+		{name: "all events kept", events: full, since: since, want: full[len(full)-1].CreatedAt},
+		{name: "events kept reach back further", events: full, since: now.Add(-time.Hour), want: now.Add(-time.Hour)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := eventsSince(tc.events, tc.since)
+			if got.Sub(tc.want) > time.Second || tc.want.Sub(got) > time.Second {
+				t.Errorf("eventsSince = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Response from Github API for info on an organization
 // e.g. https://api.github.com/orgs/[org name]
@@ -212,8 +215,19 @@ type ghCollaborators []struct {
 
 // Struct to hold the user data we want for each admin user in a repo
 type ghNameDetail struct {
-	Name  string
-	Email string
+	Name        string
+	Email       string
+	EmailSource string // Where Email came from e.g. profile, verified-domain or commit
+	// Email was already looked for by resolveEmails, found or not, so it isn't looked up again
+	EmailResolved bool
+	SSOStatus     string // Empty if SSO identities weren't collected, otherwise linked or unlinked
+	SSONameID     string
+	SSOEmail      string
+	Suspended     bool // Account is suspended, only reported by Github Enterprise Server
+	Departed      bool // Account no longer exists
+	// Status and manager from the employee roster, empty unless a roster was provided
+	RosterStatus string
+	Manager      string
 }
 
 // Response from Github API for info on a user
@@ -252,4 +266,317 @@ type ghUser struct {
 	Following         int       `json:"following"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
+	// Only returned by Github Enterprise Server
+	SuspendedAt *time.Time `json:"suspended_at"`
+}
+
+// Struct to hold all the data collected for a single Github org
+type ghOrgData struct {
+	Org     string
+	Info    ghOrgInfo
+	Repos   ghRepoInfo
+	Collabs map[string]ghCollaborators
+	Admins  map[string]ghCollaborators
+	Names   map[string]ghNameDetail
+	// SAML SSO identities keyed by lower case Github username, nil unless SSO identities were collected
+	SSO map[string]ghSSOIdentity
+	// Org members without a linked SAML SSO identity, empty unless SSO identities were collected
+	SSOUnlinked []string
+	// Pending org and repo invitations, empty unless invitations were collected
+	Invitations []ghInvite
+	// Lower case logins of the org's outside collaborators and of the collaborators added
+	// directly to each repo (keyed by repo name), nil unless access sources were collected
+	Outside map[string]bool
+	Direct  map[string]map[string]bool
+	// Repos whose default branch is protected, nil unless branch protection was collected
+	Protected map[string]bool
+	// Lower case logins of the org owners, nil unless orphaned repos were looked for
+	Owners map[string]bool
+	// Employee roster, nil unless a roster was provided
+	Roster *roster
+	// Logins of the org members, nil unless SSO identities were collected or a roster was provided
+	Members []string
+	// Admins recommended a lower role, nil unless activity was looked for
+	Recommendations []ghRecommendation
+}
+
+// Request body sent to the Github GraphQL API
+// see https://docs.github.com/en/graphql/guides/forming-calls-with-graphql
+type ghGraphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response from the Github GraphQL API, Data is left raw so each caller can
+// unmarshal it into the struct matching its query
+type ghGraphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Response from Github GraphQL API for the organizations in an enterprise account
+// see https://docs.github.com/en/graphql/reference/objects#enterprise
+type ghEnterpriseOrgs struct {
+	Enterprise struct {
+		Organizations struct {
+			Nodes []struct {
+				Login string `json:"login"`
+			} `json:"nodes"`
+			PageInfo ghPageInfo `json:"pageInfo"`
+		} `json:"organizations"`
+	} `json:"enterprise"`
+}
+
+// Cursor based pagination info returned by the Github GraphQL API
+// see https://docs.github.com/en/graphql/reference/objects#pageinfo
+type ghPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// Struct to hold a user who is a repo admin in more than one Github org
+type ghCrossOrgAdmin struct {
+	Login       string   `json:"login"`
+	Name        string   `json:"name"`
+	Email       string   `json:"email"`
+	EmailSource string   `json:"email_source,omitempty"`
+	Orgs        []string `json:"orgs"`
+	Repos       []string `json:"repos"`
+}
+
+// Struct to hold a repo without an active admin outside of the org owners
+type ghOrphanedRepo struct {
+	Org    string   `json:"org"`
+	Repo   string   `json:"repo"`
+	Reason string   `json:"reason"`
+	Admins []string `json:"admins"`
+}
+
+// Response from Github GraphQL API for a user's emails on an org's verified domains
+// see https://docs.github.com/en/graphql/reference/objects#user
+type ghVerifiedEmails struct {
+	User struct {
+		OrganizationVerifiedDomainEmails []string `json:"organizationVerifiedDomainEmails"`
+	} `json:"user"`
+}
+
+// Response from Github API for a list of commits in a repo
+// e.g. https://api.github.com/repos/[org name]/[repo name]/commits?author=[GH username]
+// see https://docs.github.com/en/rest/commits/commits#list-commits
+type ghCommits []struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Author struct {
+			Name  string    `json:"name"`
+			Email string    `json:"email"`
+			Date  time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+	Author struct {
+		Login string `json:"login"`
+	} `json:"author"`
+}
+
+// Response from Github API for the members of an organization
+// e.g. https://api.github.com/orgs/[org name]/members
+// see https://docs.github.com/en/rest/orgs/members#list-organization-members
+type ghMembers []struct {
+	Login     string `json:"login"`
+	ID        int    `json:"id"`
+	NodeID    string `json:"node_id"`
+	URL       string `json:"url"`
+	HTMLURL   string `json:"html_url"`
+	Type      string `json:"type"`
+	SiteAdmin bool   `json:"site_admin"`
+}
+
+// Response from Github GraphQL API for the SAML SSO identities linked to an org's members
+// see https://docs.github.com/en/graphql/reference/objects#externalidentity
+type ghExternalIdentities struct {
+	Organization struct {
+		SamlIdentityProvider *struct {
+			ExternalIdentities struct {
+				Nodes []struct {
+					GUID         string `json:"guid"`
+					SamlIdentity struct {
+						NameID   string `json:"nameId"`
+						Username string `json:"username"`
+						Emails   []struct {
+							Value string `json:"value"`
+						} `json:"emails"`
+					} `json:"samlIdentity"`
+					User *struct {
+						Login string `json:"login"`
+					} `json:"user"`
+				} `json:"nodes"`
+				PageInfo ghPageInfo `json:"pageInfo"`
+			} `json:"externalIdentities"`
+		} `json:"samlIdentityProvider"`
+	} `json:"organization"`
+}
+
+// Struct to hold the SAML SSO identity linked to a Github user
+type ghSSOIdentity struct {
+	NameID string
+	Email  string
+}
+
+// Response from Github API for an organization's pending invitations
+// e.g. https://api.github.com/orgs/[org name]/invitations
+// see https://docs.github.com/en/rest/orgs/members#list-pending-organization-invitations
+type ghOrgInvitations []struct {
+	ID        int       `json:"id"`
+	Login     string    `json:"login"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	FailedAt  string    `json:"failed_at"`
+	Inviter   struct {
+		Login string `json:"login"`
+	} `json:"inviter"`
+	TeamCount int `json:"team_count"`
+}
+
+// Response from Github API for a repo's pending collaborator invitations
+// e.g. https://api.github.com/repos/[org name]/[repo name]/invitations
+// see https://docs.github.com/en/rest/collaborators/invitations#list-repository-invitations
+type ghRepoInvitations []struct {
+	ID         int `json:"id"`
+	Repository struct {
+		Name     string `json:"name"`
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Invitee *struct {
+		Login string `json:"login"`
+	} `json:"invitee"`
+	Inviter struct {
+		Login string `json:"login"`
+	} `json:"inviter"`
+	Permissions string    `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	Expired     bool      `json:"expired"`
+}
+
+// Struct to hold a pending invitation to an org or repo
+type ghInvite struct {
+	ID        int       `json:"id"`
+	Level     string    `json:"level"`          // org or repo
+	Repo      string    `json:"repo,omitempty"` // Empty for org invitations
+	Invitee   string    `json:"invitee"`        // Github username or email for org invitations sent by email
+	Inviter   string    `json:"inviter"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	AgeDays   int       `json:"age_days"`
+	Stale     bool      `json:"stale"`
+}
+
+// Struct to hold an admin with no recent write activity and the lower role suggested for them
+type ghRecommendation struct {
+	Org           string `json:"org"`
+	Repo          string `json:"repo"`
+	Login         string `json:"login"`
+	CurrentRole   string `json:"current_role"`
+	Source        string `json:"source"` // direct or outside-collaborator, which can be downgraded repo by repo
+	SuggestedRole string `json:"suggested_role"`
+	LastActivity  string `json:"last_activity,omitempty"`
+	Evidence      string `json:"evidence"`
+}
+
+// Response from Github API for a repo's recent events
+// e.g. https://api.github.com/repos/[org name]/[repo name]/events
+// see https://docs.github.com/en/rest/activity/events#list-repository-events
+type ghEvents []ghEvent
+
+type ghEvent struct {
+	Type  string `json:"type"`
+	Actor struct {
+		Login string `json:"login"`
+	} `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Response from Github API for a repo's issues
+// e.g. https://api.github.com/repos/[org name]/[repo name]/issues
+// see https://docs.github.com/en/rest/issues/issues#list-repository-issues
+type ghIssues []ghIssue
+
+type ghIssue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	State  string `json:"state"`
+	// Set when the issue is a pull request
+	PullRequest *struct {
+		URL string `json:"url"`
+	} `json:"pull_request"`
+}
+
+// Response from Github API for a repo's branches
+// e.g. https://api.github.com/repos/[org name]/[repo name]/branches?protected=true
+// see https://docs.github.com/en/rest/branches/branches#list-branches
+type ghBranches []struct {
+	Name      string `json:"name"`
+	Protected bool   `json:"protected"`
+}
+
+// Struct to hold a member or outside collaborator of an org and their role
+type ghOrgMember struct {
+	Org   string `json:"org"`
+	Login string `json:"login"`
+	Role  string `json:"role"` // admin for org owners, member or outside-collaborator
+	// Empty unless user details were looked up
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// Response from Github API for an organization's teams
+// e.g. https://api.github.com/orgs/[org name]/teams
+// see https://docs.github.com/en/rest/teams/teams#list-teams
+type ghTeams []struct {
+	ID          int    `json:"id"`
+	NodeID      string `json:"node_id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Privacy     string `json:"privacy"`
+	Permission  string `json:"permission"`
+	HTMLURL     string `json:"html_url"`
+	Parent      *struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"parent"`
+}
+
+// Response from Github API for the repos a team has access to
+// e.g. https://api.github.com/orgs/[org name]/teams/[team slug]/repos
+// see https://docs.github.com/en/rest/teams/teams#list-team-repositories
+type ghTeamRepos []struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	Private     bool   `json:"private"`
+	HTMLURL     string `json:"html_url"`
+	Permissions struct {
+		Admin    bool `json:"admin"`
+		Maintain bool `json:"maintain"`
+		Push     bool `json:"push"`
+		Triage   bool `json:"triage"`
+		Pull     bool `json:"pull"`
+	} `json:"permissions"`
+	RoleName string `json:"role_name"`
+}
+
+// Struct to hold a team's permission on one repo, with an empty repo for teams without any
+type ghTeamAccess struct {
+	Org        string   `json:"org"`
+	Team       string   `json:"team"`
+	Slug       string   `json:"slug"`
+	Privacy    string   `json:"privacy"`
+	Parent     string   `json:"parent,omitempty"`
+	Members    []string `json:"members"`
+	Repo       string   `json:"repo,omitempty"`
+	Permission string   `json:"permission,omitempty"`
 }
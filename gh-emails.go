@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Sources an admin's email address can come from
+const (
+	emailProfile  = "profile"
+	emailVerified = "verified-domain"
+	emailCommit   = "commit"
+)
+
+// Query for a user's emails that are on one of an org's verified domains
+// see https://docs.github.com/en/graphql/reference/objects#user
+const verifiedEmailsQuery = `query($login: String!, $org: String!) {
+  user(login: $login) {
+    organizationVerifiedDomainEmails(login: $org)
+  }
+}`
+
+// resolveEmails takes a pointer to ghAPIClient, the org's repos and admins plus the name lookup
+// map and fills in an email for any admin without a public one on their Github profile. Emails
+// on the org's verified domains are used first with the author email of the admin's most recent
+// commit to one of the repos they admin as the fallback. Lookup failures for a single user are
+// printed but don't stop the run since the email is only a nice to have. Each admin is only
+// looked up once, even when called repo by repo while streaming
+func resolveEmails(g *ghAPIClient, repos ghRepoInfo, adm map[string]ghCollaborators, lu map[string]ghNameDetail) error {
+	// Gather the repos each admin without an email can admin
+	var logins []string
+	adminOf := make(map[string][]string)
+	for _, r := range repos {
+		for _, v := range adm[r.Name] {
+			if len(lu[v.Login].Email) > 0 || lu[v.Login].EmailResolved {
+				continue
+			}
+			if _, exists := adminOf[v.Login]; !exists {
+				logins = append(logins, v.Login)
+			}
+			adminOf[v.Login] = append(adminOf[v.Login], r.Name)
+		}
+	}
+
+	for _, l := range logins {
+		d := lu[l]
+		d.EmailResolved = true
+		lu[l] = d
+
+		// Verified domain emails are only available for organizations
+		if g.OwnerType == ownerOrg {
+			e, err := verifiedDomainEmail(g, l)
+			if err != nil {
+				fmt.Printf("Unable to get verified domain emails for %s: %v\n", l, err)
+			}
+			if len(e) > 0 {
+				setEmail(lu, l, e, emailVerified)
+				continue
+			}
+		}
+
+		// Fall back to the email on the admin's most recent commit
+		for _, r := range adminOf[l] {
+			e, err := commitEmail(g, r, l)
+			if err != nil {
+				fmt.Printf("Unable to get commit email for %s in %s: %v\n", l, r, err)
+				continue
+			}
+			if len(e) > 0 {
+				setEmail(lu, l, e, emailCommit)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifiedDomainEmail takes a pointer to ghAPIClient and a Github username and returns the first
+// of the user's emails that is on one of the current org's verified domains or an empty string
+// see https://docs.github.com/en/organizations/managing-organization-settings/verifying-or-approving-a-domain-for-your-organization
+func verifiedDomainEmail(g *ghAPIClient, l string) (string, error) {
+	tempEmails := ghVerifiedEmails{}
+	vars := map[string]interface{}{"login": l, "org": g.Org}
+	err := graphQL(g, verifiedEmailsQuery, vars, &tempEmails)
+	if err != nil {
+		return "", err
+	}
+
+	if len(tempEmails.User.OrganizationVerifiedDomainEmails) == 0 {
+		return "", nil
+	}
+
+	return tempEmails.User.OrganizationVerifiedDomainEmails[0], nil
+}
+
+// commitEmail takes a pointer to ghAPIClient, a repo name and a Github username and returns
+// the author email from the user's most recent commits to that repo, skipping Github's
+// noreply addresses, or an empty string if there isn't one
+// see https://docs.github.com/en/rest/commits/commits#list-commits
+func commitEmail(g *ghAPIClient, repo string, l string) (string, error) {
+	tempCommits := ghCommits{}
+	err := getJSON(g, "/repos/"+g.Org+"/"+repo+"/commits?per_page=10&author="+url.QueryEscape(l), "Repo commits", &tempCommits)
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range tempCommits {
+		e := v.Commit.Author.Email
+		if len(e) == 0 || strings.HasSuffix(strings.ToLower(e), "noreply.github.com") {
+			continue
+		}
+		return e, nil
+	}
+
+	return "", nil
+}
+
+// setEmail records an email address and where it came from for a user in the lookup map
+func setEmail(lu map[string]ghNameDetail, l string, e string, src string) {
+	d := lu[l]
+	d.Email = e
+	d.EmailSource = src
+	lu[l] = d
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveEmailsOncePerLogin(t *testing.T) {
+	calls := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/graphql":
+			fmt.Fprint(w, `{"data":{"user":{"organizationVerifiedDomainEmails":[]}}}`)
+		case strings.HasSuffix(r.URL.Path, "/commits") && r.URL.Query().Get("author") == "committer":
+			fmt.Fprint(w, `[{"commit":{"author":{"email":"c@noreply.github.com"}}},{"commit":{"author":{"email":"c@example.com"}}}]`)
+		case strings.HasSuffix(r.URL.Path, "/commits"):
+			fmt.Fprint(w, `[]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	g := testClient(t, srv)
+
+	d := testOrgData(t, "acme", `[{"name":"api"},{"name":"web"},{"name":"docs"}]`, nil)
+	for _, r := range []string{"api", "web", "docs"} {
+		d.Admins[r] = testOrgData(t, "acme", `[]`, map[string]string{
+			r: `[{"login":"unknown"},{"login":"committer"},{"login":"public"}]`,
+		}).Collabs[r]
+	}
+	d.Names = map[string]ghNameDetail{"public": {Email: "p@example.com", EmailSource: emailProfile}}
+
+	// Resolve a repo at a time as streaming does
+	for k := range d.Repos {
+		err := resolveEmails(g, d.Repos[k:k+1], d.Admins, d.Names)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls["/graphql"] != 2 {
+		t.Errorf("made %d verified domain queries, want 1 each for unknown and committer", calls["/graphql"])
+	}
+	if calls["/repos/acme/api/commits"] != 2 || calls["/repos/acme/web/commits"] != 0 || calls["/repos/acme/docs/commits"] != 0 {
+		t.Errorf("made commit searches %v, want 2 for the first repo only", calls)
+	}
+	if d.Names["committer"].Email != "c@example.com" || d.Names["committer"].EmailSource != emailCommit {
+		t.Errorf("committer resolved to %+v, want the commit email", d.Names["committer"])
+	}
+	if len(d.Names["unknown"].Email) > 0 || !d.Names["unknown"].EmailResolved {
+		t.Errorf("unknown resolved to %+v, want no email and marked as resolved", d.Names["unknown"])
+	}
+	if d.Names["public"].Email != "p@example.com" || d.Names["public"].EmailSource != emailProfile {
+		t.Errorf("public resolved to %+v, want the profile email kept", d.Names["public"])
+	}
+}
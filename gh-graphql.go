@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Query to list the organizations in a Github Enterprise account
+// see https://docs.github.com/en/graphql/reference/objects#enterprise
+const enterpriseOrgsQuery = `query($slug: String!, $cursor: String) {
+  enterprise(slug: $slug) {
+    organizations(first: 100, after: $cursor) {
+      nodes { login }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}`
+
+// graphQL takes a pointer to ghAPIClient, a GraphQL query and its variables and
+// sends them to the Github GraphQL API, unmarshalling the data returned into d
+// see https://docs.github.com/en/graphql/guides/forming-calls-with-graphql
+func graphQL(g *ghAPIClient, q string, vars map[string]interface{}, d interface{}) error {
+	// Add the URI for the GraphQL endpoint, which Github Enterprise Server has at /api/graphql
+	// rather than under the REST API's /api/v3
+	u := *g.BaseURL
+	u.Path = strings.TrimSuffix(u.Path, "/v3") + "/graphql"
+	g.FullURL = &u
+
+	// Setup the request body
+	rawReq, err := json.Marshal(ghGraphQLRequest{Query: q, Variables: vars})
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem marshalling GraphQL query was: %v", err))
+	}
+
+	// Setup the request
+	req, err := http.NewRequest(http.MethodPost, g.FullURL.String(), bytes.NewReader(rawReq))
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem preparing Request was: %v", err))
+	}
+	req.Header.Add("content-type", "application/json")
+	req.Header.Add(g.Header, g.Token)
+
+	// Send the request
+	resp, err := g.HttpClient.Do(req)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem sending Request was: %v", err))
+	}
+	defer resp.Body.Close()
+
+	// Read the response body
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem reading response body was: %v", err))
+	}
+
+	// Check the response code
+	if resp.StatusCode != 200 {
+		return errors.New(fmt.Sprintf("API response code for GraphQL query was: %v", resp.StatusCode))
+	}
+
+	// Unmarshall the response, checking for GraphQL errors which come back with a 200
+	tempResp := ghGraphQLResponse{}
+	err = json.Unmarshal(body, &tempResp)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem unmarshalling JSON was: %v", err))
+	}
+	if len(tempResp.Errors) > 0 {
+		var msgs []string
+		for _, v := range tempResp.Errors {
+			msgs = append(msgs, v.Message)
+		}
+		return errors.New(fmt.Sprintf("GraphQL query returned errors: %s", strings.Join(msgs, "; ")))
+	}
+
+	// Unmarshall data to the caller's struct
+	err = json.Unmarshal(tempResp.Data, d)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem unmarshalling JSON was: %v", err))
+	}
+
+	return nil
+}
+
+// getEnterpriseOrgs takes a pointer to ghAPIClient and adds the login of every org in the
+// Github Enterprise account (based on the Enterprise field of ghAPIClient) to the Orgs field.
+// Orgs already provided with the org argument are not added a second time
+func getEnterpriseOrgs(g *ghAPIClient) error {
+	// Track orgs already in the list
+	seen := make(map[string]bool)
+	for _, v := range g.Orgs {
+		seen[strings.ToLower(v)] = true
+	}
+
+	// Page through the enterprise's organizations
+	vars := map[string]interface{}{"slug": g.Enterprise}
+	for {
+		tempEnt := ghEnterpriseOrgs{}
+		err := graphQL(g, enterpriseOrgsQuery, vars, &tempEnt)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range tempEnt.Enterprise.Organizations.Nodes {
+			if seen[strings.ToLower(v.Login)] {
+				continue
+			}
+			seen[strings.ToLower(v.Login)] = true
+			g.Orgs = append(g.Orgs, v.Login)
+		}
+
+		if !tempEnt.Enterprise.Organizations.PageInfo.HasNextPage {
+			break
+		}
+		vars["cursor"] = tempEnt.Enterprise.Organizations.PageInfo.EndCursor
+	}
+
+	if len(g.Orgs) == 0 {
+		return errors.New(fmt.Sprintf("No organizations found for Enterprise %s", g.Enterprise))
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Levels an invitation can be sent at
+const (
+	inviteOrg  = "org"
+	inviteRepo = "repo"
+)
+
+// collectInvitations takes a pointer to ghAPIClient and ghOrgData and gathers the pending
+// invitations for the org (if the account is an org) and each of its repos. Invitations
+// older than the StaleInviteDays field of ghAPIClient, or already expired, are flagged stale
+func collectInvitations(g *ghAPIClient, d *ghOrgData) error {
+	now := time.Now()
+
+	// Org level invitations
+	if g.OwnerType == ownerOrg {
+		oInvites := ghOrgInvitations{}
+		err := getOrgInvitations(g, &oInvites)
+		if err != nil {
+			return err
+		}
+		for _, v := range oInvites {
+			i := ghInvite{
+				ID:        v.ID,
+				Level:     inviteOrg,
+				Invitee:   v.Login,
+				Inviter:   v.Inviter.Login,
+				Role:      v.Role,
+				CreatedAt: v.CreatedAt,
+			}
+			if len(i.Invitee) == 0 {
+				i.Invitee = v.Email
+			}
+			setInviteAge(g, &i, now, len(v.FailedAt) > 0)
+			d.Invitations = append(d.Invitations, i)
+		}
+	}
+
+	// Repo level invitations
+	for _, r := range d.Repos {
+		rInvites := ghRepoInvitations{}
+		err := getRepoInvitations(g, r.Name, &rInvites)
+		if err != nil {
+			return err
+		}
+		for _, v := range rInvites {
+			i := ghInvite{
+				ID:        v.ID,
+				Level:     inviteRepo,
+				Repo:      r.Name,
+				Inviter:   v.Inviter.Login,
+				Role:      v.Permissions,
+				CreatedAt: v.CreatedAt,
+			}
+			if v.Invitee != nil {
+				i.Invitee = v.Invitee.Login
+			}
+			setInviteAge(g, &i, now, v.Expired)
+			d.Invitations = append(d.Invitations, i)
+		}
+	}
+
+	// Oldest invitations first
+	sort.SliceStable(d.Invitations, func(i, j int) bool {
+		return d.Invitations[i].CreatedAt.Before(d.Invitations[j].CreatedAt)
+	})
+
+	return nil
+}
+
+// setInviteAge fills in the age in days of an invitation and flags it as stale if it
+// is older than the StaleInviteDays field of ghAPIClient or has expired or failed
+func setInviteAge(g *ghAPIClient, i *ghInvite, now time.Time, expired bool) {
+	i.AgeDays = int(now.Sub(i.CreatedAt).Hours() / 24)
+	i.Stale = expired || i.AgeDays >= g.StaleInviteDays
+}
+
+// getOrgInvitations takes pointers to ghAPIClient and ghOrgInvitations and retrieves
+// all the pending invitations for the current org
+// see https://docs.github.com/en/rest/orgs/members#list-pending-organization-invitations
+func getOrgInvitations(g *ghAPIClient, inv *ghOrgInvitations) error {
+	return getAllPages(g, "/orgs/"+g.Org+"/invitations", "Org invitations", func(page []byte) error {
+		tempInvites := ghOrgInvitations{}
+		err := json.Unmarshal(page, &tempInvites)
+		*inv = append(*inv, tempInvites...)
+		return err
+	})
+}
+
+// getRepoInvitations takes a pointer to ghAPIClient, a repo name and a pointer to
+// ghRepoInvitations and retrieves all the pending collaborator invitations for the repo
+// see https://docs.github.com/en/rest/collaborators/invitations#list-repository-invitations
+func getRepoInvitations(g *ghAPIClient, repo string, inv *ghRepoInvitations) error {
+	return getAllPages(g, "/repos/"+g.Org+"/"+repo+"/invitations", "Repo invitations", func(page []byte) error {
+		tempInvites := ghRepoInvitations{}
+		err := json.Unmarshal(page, &tempInvites)
+		*inv = append(*inv, tempInvites...)
+		return err
+	})
+}
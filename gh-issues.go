@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Label put on every tracking issue so reruns find the issues they opened
+const issueLabel = "ghorg2csv-policy"
+
+// Title of the tracking issue opened in a repo which breaks the policy
+const issueTitle = "ghorg2csv: policy violations"
+
+// syncIssues takes a pointer to ghAPIClient and the data collected for one or more orgs and opens,
+// updates or closes a tracking issue for each repo so it matches the policy violations found. The
+// issues are opened in each repo, or in the central repo set in the IssuesRepo field of ghAPIClient.
+// The changes are only printed unless the IssuesApply field of ghAPIClient is set
+func syncIssues(g *ghAPIClient, orgs []ghOrgData) error {
+	// Group the violations by repo
+	byRepo := make(map[string][]policyViolation)
+	for _, v := range g.Violations {
+		byRepo[v.Org+"/"+v.Repo] = append(byRepo[v.Org+"/"+v.Repo], v)
+	}
+
+	// Find the tracking issues already open in the central repo
+	var central map[string]ghIssue
+	if len(g.IssuesRepo) > 0 {
+		open := ghIssues{}
+		err := getLabeledIssues(g, g.IssuesRepo, &open)
+		if err != nil {
+			return err
+		}
+		central = make(map[string]ghIssue)
+		for _, v := range open {
+			central[v.Title] = v
+		}
+	}
+
+	var opened, updated, closed int
+	for _, o := range orgs {
+		for _, r := range o.Repos {
+			name := o.Org + "/" + r.Name
+			target := g.IssuesRepo
+			title := issueTitle + " in " + name
+			var existing ghIssue
+			found := false
+
+			if len(target) > 0 {
+				existing, found = central[title]
+			} else {
+				// Issues can't be opened in archived repos or those with issues turned off
+				if r.Archived || !r.HasIssues {
+					continue
+				}
+				// Without violations there's only a tracking issue to close if the repo has open issues
+				if len(byRepo[name]) == 0 && r.OpenIssuesCount == 0 {
+					continue
+				}
+				target = name
+				title = issueTitle
+				open := ghIssues{}
+				err := getLabeledIssues(g, target, &open)
+				if err != nil {
+					return err
+				}
+				for _, v := range open {
+					if v.Title == title {
+						existing, found = v, true
+						break
+					}
+				}
+			}
+
+			body := issueBody(name, byRepo[name])
+			switch {
+			case len(byRepo[name]) > 0 && !found:
+				opened++
+				if !g.IssuesApply {
+					fmt.Printf("  Would open a tracking issue in %s for %d violations in %s\n", target, len(byRepo[name]), name)
+					continue
+				}
+				err := createIssue(g, target, title, body)
+				if err != nil {
+					return err
+				}
+			case len(byRepo[name]) > 0 && existing.Body != body:
+				updated++
+				if !g.IssuesApply {
+					fmt.Printf("  Would update tracking issue %s#%d for %d violations in %s\n", target, existing.Number, len(byRepo[name]), name)
+					continue
+				}
+				err := updateIssue(g, target, existing.Number, body)
+				if err != nil {
+					return err
+				}
+			case len(byRepo[name]) == 0 && found:
+				closed++
+				if !g.IssuesApply {
+					fmt.Printf("  Would close tracking issue %s#%d as %s has no violations\n", target, existing.Number, name)
+					continue
+				}
+				err := closeIssue(g, target, existing.Number)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if !g.IssuesApply {
+		fmt.Printf("Tracking issues: %d to open, %d to update, %d to close, rerun with -issues-apply to make the changes\n",
+			opened, updated, closed)
+		return nil
+	}
+	fmt.Printf("Tracking issues: %d opened, %d updated, %d closed\n", opened, updated, closed)
+
+	return nil
+}
+
+// issueBody returns the markdown body of the tracking issue for a repo's policy violations
+func issueBody(repo string, found []policyViolation) string {
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].RuleID != found[j].RuleID {
+			return found[i].RuleID < found[j].RuleID
+		}
+		return found[i].Message < found[j].Message
+	})
+
+	var b strings.Builder
+	b.WriteString("`" + repo + "` breaks the following policy rules.\n\n")
+	b.WriteString("| Severity | Rule | Message |\n")
+	b.WriteString("|---|---|---|\n")
+	for _, v := range found {
+		b.WriteString("| " + v.Severity + " | " + v.RuleID + " | " + strings.ReplaceAll(v.Message, "|", "\\|") + " |\n")
+	}
+	b.WriteString("\nThis issue is kept up to date by ghorg2csv and closed once every violation is resolved.\n")
+
+	return b.String()
+}
+
+// getLabeledIssues takes a pointer to ghAPIClient, a repo full name and a pointer to ghIssues and
+// retrieves the open issues in the repo with the tracking label, leaving out pull requests
+// see https://docs.github.com/en/rest/issues/issues#list-repository-issues
+func getLabeledIssues(g *ghAPIClient, repo string, i *ghIssues) error {
+	u := "/repos/" + repo + "/issues?state=open&labels=" + issueLabel
+	return getAllPages(g, u, "Repo issues", func(page []byte) error {
+		tempIssues := ghIssues{}
+		err := json.Unmarshal(page, &tempIssues)
+		for _, v := range tempIssues {
+			if v.PullRequest == nil {
+				*i = append(*i, v)
+			}
+		}
+		return err
+	})
+}
+
+// createIssue opens a tracking issue in a repo
+// see https://docs.github.com/en/rest/issues/issues#create-an-issue
+func createIssue(g *ghAPIClient, repo string, title string, body string) error {
+	issue := map[string]interface{}{"title": title, "body": body, "labels": []string{issueLabel}}
+	return mutate(g, http.MethodPost, "/repos/"+repo+"/issues", issue, "Create issue")
+}
+
+// updateIssue replaces the body of a tracking issue
+// see https://docs.github.com/en/rest/issues/issues#update-an-issue
+func updateIssue(g *ghAPIClient, repo string, n int, body string) error {
+	issue := map[string]string{"body": body}
+	return mutate(g, http.MethodPatch, "/repos/"+repo+"/issues/"+strconv.Itoa(n), issue, "Update issue")
+}
+
+// closeIssue comments that the violations are resolved and closes a tracking issue
+// see https://docs.github.com/en/rest/issues/comments#create-an-issue-comment
+func closeIssue(g *ghAPIClient, repo string, n int) error {
+	comment := map[string]string{"body": "Every policy violation has been resolved, closing."}
+	err := mutate(g, http.MethodPost, "/repos/"+repo+"/issues/"+strconv.Itoa(n)+"/comments", comment, "Comment on issue")
+	if err != nil {
+		return err
+	}
+	issue := map[string]string{"state": "closed", "state_reason": "completed"}
+	return mutate(g, http.MethodPatch, "/repos/"+repo+"/issues/"+strconv.Itoa(n), issue, "Close issue")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestSyncIssues(t *testing.T) {
+	violations := []policyViolation{
+		{RuleID: "license", Severity: "low", Org: "acme", Repo: "api", Message: "Repo has no license"},
+		{RuleID: "license", Severity: "low", Org: "acme", Repo: "web", Message: "Repo has no license"},
+	}
+	webBody := issueBody("acme/web", violations[1:])
+
+	tests := []struct {
+		name      string
+		central   string
+		apply     bool
+		lists     []string
+		mutations []string
+	}{
+		{
+			name:  "dry run",
+			lists: []string{"/repos/acme/api/issues", "/repos/acme/docs/issues", "/repos/acme/web/issues"},
+		},
+		{
+			name:  "apply",
+			apply: true,
+			lists: []string{"/repos/acme/api/issues", "/repos/acme/docs/issues", "/repos/acme/web/issues"},
+			mutations: []string{
+				"POST /repos/acme/api/issues",
+				"POST /repos/acme/docs/issues/7/comments",
+				"PATCH /repos/acme/docs/issues/7",
+			},
+		},
+		{
+			name:    "central repo",
+			central: "acme/tracking",
+			apply:   true,
+			lists:   []string{"/repos/acme/tracking/issues"},
+			mutations: []string{
+				"POST /repos/acme/tracking/issues",
+				"POST /repos/acme/tracking/issues/3/comments",
+				"PATCH /repos/acme/tracking/issues/3",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var lists, mutations []string
+			var mu sync.Mutex
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if r.Method != http.MethodGet {
+					mutations = append(mutations, r.Method+" "+r.URL.Path)
+					w.WriteHeader(http.StatusCreated)
+					fmt.Fprint(w, `{}`)
+					return
+				}
+				lists = append(lists, r.URL.Path)
+				switch r.URL.Path {
+				case "/repos/acme/web/issues":
+					fmt.Fprintf(w, `[{"number":5,"title":%q,"body":%q}]`, issueTitle, webBody)
+				case "/repos/acme/docs/issues":
+					fmt.Fprintf(w, `[{"number":7,"title":%q,"body":"old"},{"number":8,"title":"PR","pull_request":{}}]`, issueTitle)
+				case "/repos/acme/tracking/issues":
+					fmt.Fprintf(w, `[{"number":2,"title":%q,"body":%q},{"number":3,"title":%q,"body":"old"}]`,
+						issueTitle+" in acme/web", webBody, issueTitle+" in acme/docs")
+				default:
+					fmt.Fprint(w, `[]`)
+				}
+			}))
+			defer srv.Close()
+			g := testClient(t, srv)
+			g.Violations = violations
+			g.IssuesRepo = tc.central
+			g.IssuesApply = tc.apply
+
+			// quiet has no violations or open issues so can't have a tracking issue to close,
+			// old is archived and off has issues turned off
+			d := testOrgData(t, "acme", `[{"name":"api","has_issues":true},{"name":"web","has_issues":true,"open_issues_count":1},`+
+				`{"name":"docs","has_issues":true,"open_issues_count":2},{"name":"quiet","has_issues":true},`+
+				`{"name":"old","archived":true,"has_issues":true,"open_issues_count":1},{"name":"off","open_issues_count":1}]`, nil)
+			err := syncIssues(g, []ghOrgData{d})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Strings(lists)
+			if !reflect.DeepEqual(lists, tc.lists) {
+				t.Errorf("syncIssues listed the issues of\n%q\nwant\n%q", lists, tc.lists)
+			}
+			if !reflect.DeepEqual(mutations, tc.mutations) {
+				t.Errorf("syncIssues sent\n%q\nwant\n%q", mutations, tc.mutations)
+			}
+		})
+	}
+}
+
+func TestIssueBody(t *testing.T) {
+	got := issueBody("acme/api", []policyViolation{
+		{RuleID: "no-outside-admin", Severity: "high", Message: "Outside collaborator jdoe is an admin"},
+		{RuleID: "license", Severity: "low", Message: "Repo has no license"},
+		{RuleID: "no-outside-admin", Severity: "high", Message: "Outside collaborator asmith | contractor is an admin"},
+	})
+	want := "`acme/api` breaks the following policy rules.\n\n" +
+		"| Severity | Rule | Message |\n" +
+		"|---|---|---|\n" +
+		"| low | license | Repo has no license |\n" +
+		"| high | no-outside-admin | Outside collaborator asmith \\| contractor is an admin |\n" +
+		"| high | no-outside-admin | Outside collaborator jdoe is an admin |\n" +
+		"\nThis issue is kept up to date by ghorg2csv and closed once every violation is resolved.\n"
+	if got != want {
+		t.Errorf("issueBody =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// membersCommand handles the members command which lists the members of orgs with their role,
+// exiting when done
+func membersCommand(args []string) {
+	fs := newFlagSet("members")
+	a := reportArgs{}
+	var outside, details bool
+	listFlags(fs, &a, "members.csv")
+	fs.BoolVar(&outside, "outside", false, "Include the outside collaborators of each org")
+	fs.BoolVar(&details, "details", false, "Look up the name and email of each user, one API call per user")
+	fs.Parse(args)
+	configArgs(fs)
+	noArgs(fs)
+
+	gh := listClient(&a)
+	var members []ghOrgMember
+	for _, o := range gh.Orgs {
+		setOrg(gh, o)
+		memTime := time.Now()
+		m, err := collectMembers(gh, outside, details)
+		if err != nil {
+			fmt.Printf("Error collecting members for org %s was %+v\n", o, err)
+			os.Exit(exitError)
+		}
+		members = append(members, m...)
+		fmt.Printf("Get members for org %s done in %v\n", o, time.Since(memTime))
+	}
+
+	var err error
+	if a.format == formatJSON {
+		if members == nil {
+			members = []ghOrgMember{}
+		}
+		err = writeJSONList(gh.File, members)
+	} else {
+		err = writeMembersCSV(gh.File, members)
+	}
+	if err != nil {
+		fmt.Printf("Error writing members was %+v\n", err)
+		os.Exit(exitError)
+	}
+	fmt.Printf("Wrote %d members to %s\n", len(members), gh.File)
+}
+
+// collectMembers takes a pointer to ghAPIClient and returns the members of the current org with
+// their role, adding the outside collaborators and looking up the name and email of each user
+// when asked to
+func collectMembers(g *ghAPIClient, outside bool, details bool) ([]ghOrgMember, error) {
+	owners, err := ownerLogins(g)
+	if err != nil {
+		return nil, err
+	}
+	all := ghMembers{}
+	err = getMembers(g, "", &all)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []ghOrgMember
+	for _, v := range all {
+		m := ghOrgMember{Org: g.Org, Login: v.Login, Role: "member"}
+		if owners[strings.ToLower(v.Login)] {
+			m.Role = "admin"
+		}
+		members = append(members, m)
+	}
+
+	// Outside collaborators aren't members but have access to some of the org's repos
+	if outside {
+		collabs := ghMembers{}
+		err = getOutsideCollabs(g, &collabs)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range collabs {
+			members = append(members, ghOrgMember{Org: g.Org, Login: v.Login, Role: accessOutside})
+		}
+	}
+
+	if details {
+		lu := make(map[string]ghNameDetail)
+		for k := range members {
+			err = getSingleUser(g, members[k].Login, lu)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Problem retrieving individual user data was: %v", err))
+			}
+			members[k].Name = lu[members[k].Login].Name
+			members[k].Email = lu[members[k].Login].Email
+		}
+	}
+
+	return members, nil
+}
+
+// writeMembersCSV takes a file name and the members of one or more orgs and writes
+// them out as a CSV
+func writeMembersCSV(f string, members []ghOrgMember) error {
+	header := []string{
+		"Org",   // e.g. my-github-org
+		"Login", // e.g. jdoe
+		"Role",  // admin, member or outside-collaborator
+		"Name",  // e.g. Jane Doe, only with -details
+		"Email", // e.g. jane@example.com, only with -details
+	}
+
+	// Add a line for each member
+	var rows [][]string
+	for _, v := range members {
+		rows = append(rows, []string{v.Org, v.Login, v.Role, v.Name, v.Email})
+	}
+
+	return writeCSVRows(f, header, rows)
+}
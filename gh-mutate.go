@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Struct to hold a single line of the mutation log, written as JSON
+type ghMutation struct {
+	Time   time.Time       `json:"time"`
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Status int             `json:"status"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// mutate takes a pointer to ghAPIClient, a HTTP method, a URI, a body to send as JSON (or nil)
+// and a short description of the change (used in error messages). It sends the change to the
+// Github API and logs it to the MutationLog field of ghAPIClient whether or not it succeeded
+func mutate(g *ghAPIClient, method string, u string, body interface{}, what string) error {
+	// Add the URI for the call
+	err := addURI(g, u)
+	if err != nil {
+		return err
+	}
+
+	// Every mutation is logged, including those that fail
+	m := ghMutation{Time: time.Now().UTC(), Method: method, URL: g.FullURL.String()}
+	defer logMutation(g, &m)
+
+	// Setup the request
+	var raw []byte
+	if body != nil {
+		raw, err = json.Marshal(body)
+		if err != nil {
+			m.Error = err.Error()
+			return errors.New(fmt.Sprintf("Problem marshalling JSON was: %v", err))
+		}
+		m.Body = raw
+	}
+	req, err := http.NewRequest(method, g.FullURL.String(), bytes.NewReader(raw))
+	if err != nil {
+		m.Error = err.Error()
+		return errors.New(fmt.Sprintf("Problem preparing Request was: %v", err))
+	}
+	req.Header.Add("Accept", "application/vnd.github+json")
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	req.Header.Add(g.Header, g.Token)
+
+	// Send the request
+	resp, err := g.HttpClient.Do(req)
+	if err != nil {
+		m.Error = err.Error()
+		return errors.New(fmt.Sprintf("Problem sending Request was: %v", err))
+	}
+	defer resp.Body.Close()
+	m.Status = resp.StatusCode
+
+	// Check the response code, the body is only read to include Github's message in the error
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		m.Error = string(b)
+		return errors.New(fmt.Sprintf("API response code for %s was: %v", what, resp.StatusCode))
+	}
+
+	return nil
+}
+
+// logMutation writes a mutation to the MutationLog field of ghAPIClient as a line of JSON
+func logMutation(g *ghAPIClient, m *ghMutation) {
+	if g.MutationLog == nil {
+		return
+	}
+	err := json.NewEncoder(g.MutationLog).Encode(m)
+	if err != nil {
+		fmt.Printf("Error writing to the mutation log was %+v\n", err)
+	}
+}
+
+// removeCollaborator removes a user's direct access to a repo
+// see https://docs.github.com/en/rest/collaborators/collaborators#remove-a-repository-collaborator
+func removeCollaborator(g *ghAPIClient, org string, repo string, l string) error {
+	return mutate(g, http.MethodDelete, "/repos/"+org+"/"+repo+"/collaborators/"+l, nil, "Remove collaborator")
+}
+
+// setCollaboratorPermission sets the permission of a user added directly to a repo. For anyone
+// else it sends an invitation or adds a direct grant alongside their team or org access, so
+// callers check the user is a direct collaborator first
+// see https://docs.github.com/en/rest/collaborators/collaborators#add-a-repository-collaborator
+func setCollaboratorPermission(g *ghAPIClient, org string, repo string, l string, p string) error {
+	body := map[string]string{"permission": p}
+	return mutate(g, http.MethodPut, "/repos/"+org+"/"+repo+"/collaborators/"+l, body, "Set collaborator permission")
+}
+
+// cancelOrgInvitation cancels a pending invitation to an org
+// see https://docs.github.com/en/rest/orgs/members#cancel-an-organization-invitation
+func cancelOrgInvitation(g *ghAPIClient, org string, id int) error {
+	return mutate(g, http.MethodDelete, "/orgs/"+org+"/invitations/"+fmt.Sprint(id), nil, "Cancel org invitation")
+}
+
+// cancelRepoInvitation cancels a pending invitation to a repo
+// see https://docs.github.com/en/rest/collaborators/invitations#delete-a-repository-invitation
+func cancelRepoInvitation(g *ghAPIClient, org string, repo string, id int) error {
+	return mutate(g, http.MethodDelete, "/repos/"+org+"/"+repo+"/invitations/"+fmt.Sprint(id), nil, "Cancel repo invitation")
+}
+
+// archiveRepo archives a repo, making it read-only
+// see https://docs.github.com/en/rest/repos/repos#update-a-repository
+func archiveRepo(g *ghAPIClient, org string, repo string) error {
+	body := map[string]bool{"archived": true}
+	return mutate(g, http.MethodPatch, "/repos/"+org+"/"+repo, body, "Archive repo")
+}
+
+// Response from Github API for a user's permission on a repo
+// see https://docs.github.com/en/rest/collaborators/collaborators#get-repository-permissions-for-a-user
+type ghRepoPermission struct {
+	Permission string `json:"permission"`
+	RoleName   string `json:"role_name"`
+}
+
+// Parts of the Github API response for a repo used to check it still needs archiving
+// see https://docs.github.com/en/rest/repos/repos#get-a-repository
+type ghRepoState struct {
+	Archived bool      `json:"archived"`
+	PushedAt time.Time `json:"pushed_at"`
+}
+
+// getCollaboratorPermission returns a user's current role on a repo from any source: direct,
+// through a team or from the org, e.g. admin, maintain, write, triage, read or a custom role
+func getCollaboratorPermission(g *ghAPIClient, org string, repo string, l string) (string, error) {
+	p := ghRepoPermission{}
+	err := getJSON(g, "/repos/"+org+"/"+repo+"/collaborators/"+l+"/permission", "Collaborator permission", &p)
+	if err != nil {
+		return "", err
+	}
+	if len(p.RoleName) > 0 {
+		return p.RoleName, nil
+	}
+
+	return p.Permission, nil
+}
+
+// getRepoState returns whether a repo is archived and when it was last pushed to
+func getRepoState(g *ghAPIClient, org string, repo string) (ghRepoState, error) {
+	s := ghRepoState{}
+	err := getJSON(g, "/repos/"+org+"/"+repo, "Repo", &s)
+
+	return s, err
+}
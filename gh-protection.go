@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// collectBranchProtection takes a pointer to ghAPIClient and ghOrgData and records whether the
+// default branch of each repo is protected
+func collectBranchProtection(g *ghAPIClient, d *ghOrgData) error {
+	d.Protected = make(map[string]bool)
+	for _, r := range d.Repos {
+		// Empty repos have no default branch to protect
+		if len(r.DefaultBranch) == 0 {
+			continue
+		}
+		branches := ghBranches{}
+		err := getProtectedBranches(g, r.Name, &branches)
+		if err != nil {
+			return err
+		}
+		for _, b := range branches {
+			if b.Name == r.DefaultBranch {
+				d.Protected[r.Name] = true
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// getProtectedBranches takes a pointer to ghAPIClient, a repo name and a pointer to ghBranches
+// and retrieves the protected branches of the repo. Listing only protected branches avoids a 404
+// for repos which are empty
+// see https://docs.github.com/en/rest/branches/branches#list-branches
+func getProtectedBranches(g *ghAPIClient, repo string, b *ghBranches) error {
+	u := "/repos/" + g.Org + "/" + repo + "/branches?protected=true"
+	return getAllPages(g, u, "Repo protected branches", func(page []byte) error {
+		tempBranches := ghBranches{}
+		err := json.Unmarshal(page, &tempBranches)
+		*b = append(*b, tempBranches...)
+		return err
+	})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SAML SSO status of a Github user
+const (
+	ssoLinked   = "linked"
+	ssoUnlinked = "unlinked"
+)
+
+// Query for the SAML SSO identities linked to the members of an org
+// see https://docs.github.com/en/graphql/reference/objects#organizationidentityprovider
+const externalIdentitiesQuery = `query($org: String!, $cursor: String) {
+  organization(login: $org) {
+    samlIdentityProvider {
+      externalIdentities(first: 100, after: $cursor) {
+        nodes {
+          guid
+          samlIdentity { nameId username emails { value } }
+          user { login }
+        }
+        pageInfo { hasNextPage endCursor }
+      }
+    }
+  }
+}`
+
+// getSSO takes a pointer to ghAPIClient and ghOrgData and fills the SSO field of ghOrgData
+// with the SAML SSO identities linked to the org's members and the Members field with the
+// org's members. Orgs without SAML SSO configured are skipped, leaving the SSO field nil
+func getSSO(g *ghAPIClient, d *ghOrgData) error {
+	ids, enabled, err := getExternalIdentities(g)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		fmt.Printf("SAML SSO is not configured for org %s, skipping SSO identities\n", g.Org)
+		return nil
+	}
+	d.SSO = ids
+
+	// Only members can link an identity, so get them now to tell them from outside collaborators
+	resetMeta(g)
+	members := ghMembers{}
+	err = getMembers(g, "", &members)
+	if err != nil {
+		return err
+	}
+	for _, v := range members {
+		d.Members = append(d.Members, v.Login)
+	}
+
+	return nil
+}
+
+// applySSO takes a pointer to ghOrgData and adds the SAML SSO identity, or lack of one, to
+// the details of each user in the name lookup map. Outside collaborators can't link an identity
+// so are left without a status. Nothing is changed if the SSO field is nil
+func applySSO(d *ghOrgData) {
+	if d.SSO == nil {
+		return
+	}
+
+	for l, v := range d.Names {
+		id, exists := d.SSO[strings.ToLower(l)]
+		if !exists {
+			if containsString(d.Members, l) {
+				v.SSOStatus = ssoUnlinked
+				d.Names[l] = v
+			}
+			continue
+		}
+		v.SSOStatus = ssoLinked
+		v.SSONameID = id.NameID
+		v.SSOEmail = id.Email
+		d.Names[l] = v
+	}
+}
+
+// collectSSO takes a pointer to ghAPIClient and ghOrgData and adds the SAML SSO identity of
+// each admin to the name lookup map. Org members with no linked identity are added to the
+// SSOUnlinked field of ghOrgData. Orgs without SAML SSO configured are skipped
+func collectSSO(g *ghAPIClient, d *ghOrgData) error {
+	if d.SSO == nil {
+		return nil
+	}
+
+	// Add the SSO identity to each admin's details
+	applySSO(d)
+
+	// Flag the org members without a linked identity
+	for _, l := range d.Members {
+		if _, exists := d.SSO[strings.ToLower(l)]; !exists {
+			d.SSOUnlinked = append(d.SSOUnlinked, l)
+		}
+	}
+	sort.Strings(d.SSOUnlinked)
+
+	return nil
+}
+
+// getExternalIdentities takes a pointer to ghAPIClient and returns a map of the SAML SSO identities
+// linked to the current org's members, keyed by the lower case Github username. The returned bool
+// is false when the org doesn't have SAML SSO configured
+// see https://docs.github.com/en/graphql/reference/objects#externalidentity
+func getExternalIdentities(g *ghAPIClient) (map[string]ghSSOIdentity, bool, error) {
+	ids := make(map[string]ghSSOIdentity)
+	vars := map[string]interface{}{"org": g.Org}
+	for {
+		tempIDs := ghExternalIdentities{}
+		err := graphQL(g, externalIdentitiesQuery, vars, &tempIDs)
+		if err != nil {
+			return ids, false, errors.New(fmt.Sprintf("Problem retrieving SAML SSO identities was: %v", err))
+		}
+
+		idp := tempIDs.Organization.SamlIdentityProvider
+		if idp == nil {
+			return ids, false, nil
+		}
+
+		for _, v := range idp.ExternalIdentities.Nodes {
+			// Identities not yet linked to a Github account have no user
+			if v.User == nil {
+				continue
+			}
+			id := ghSSOIdentity{NameID: v.SamlIdentity.NameID}
+			if len(v.SamlIdentity.Emails) > 0 {
+				id.Email = v.SamlIdentity.Emails[0].Value
+			}
+			ids[strings.ToLower(v.User.Login)] = id
+		}
+
+		if !idp.ExternalIdentities.PageInfo.HasNextPage {
+			break
+		}
+		vars["cursor"] = idp.ExternalIdentities.PageInfo.EndCursor
+	}
+
+	return ids, true, nil
+}
+
+// getMembers takes pointers to ghAPIClient and ghMembers plus a role and retrieves all the
+// members of the current org with that role to fill the ghMembers struct. Use an empty role
+// for all members or admin for only the org owners
+// see https://docs.github.com/en/rest/orgs/members#list-organization-members
+func getMembers(g *ghAPIClient, role string, m *ghMembers) error {
+	u := "/orgs/" + g.Org + "/members"
+	if len(role) > 0 {
+		u = u + "?role=" + role
+	}
+	return getAllPages(g, u, "Org members", func(page []byte) error {
+		tempMembers := ghMembers{}
+		err := json.Unmarshal(page, &tempMembers)
+		*m = append(*m, tempMembers...)
+		return err
+	})
+}
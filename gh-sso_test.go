@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplySSO(t *testing.T) {
+	// jdoe is a member with a linked identity, asmith a member without one and contractor
+	// an outside collaborator, who can't link an identity
+	d := ghOrgData{
+		Members: []string{"jdoe", "asmith"},
+		Names: map[string]ghNameDetail{
+			"jdoe":       {Name: "Jane Doe"},
+			"asmith":     {Name: "Al Smith"},
+			"contractor": {Name: "Con Tractor"},
+		},
+		SSO: map[string]ghSSOIdentity{"jdoe": {NameID: "jane@example.com", Email: "jane@example.com"}},
+	}
+
+	applySSO(&d)
+	want := map[string]ghNameDetail{
+		"jdoe":       {Name: "Jane Doe", SSOStatus: ssoLinked, SSONameID: "jane@example.com", SSOEmail: "jane@example.com"},
+		"asmith":     {Name: "Al Smith", SSOStatus: ssoUnlinked},
+		"contractor": {Name: "Con Tractor"},
+	}
+	if !reflect.DeepEqual(d.Names, want) {
+		t.Errorf("applySSO set\n%+v\nwant\n%+v", d.Names, want)
+	}
+}
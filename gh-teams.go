@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// teamsCommand handles the teams command which lists the teams of orgs with their members
+// and repo permissions, exiting when done
+func teamsCommand(args []string) {
+	fs := newFlagSet("teams")
+	a := reportArgs{}
+	listFlags(fs, &a, "teams.csv")
+	fs.Parse(args)
+	configArgs(fs)
+	noArgs(fs)
+
+	gh := listClient(&a)
+	var access []ghTeamAccess
+	for _, o := range gh.Orgs {
+		setOrg(gh, o)
+		teamTime := time.Now()
+		t, err := collectTeams(gh)
+		if err != nil {
+			fmt.Printf("Error collecting teams for org %s was %+v\n", o, err)
+			os.Exit(exitError)
+		}
+		access = append(access, t...)
+		fmt.Printf("Get teams for org %s done in %v\n", o, time.Since(teamTime))
+	}
+
+	var err error
+	if a.format == formatJSON {
+		if access == nil {
+			access = []ghTeamAccess{}
+		}
+		err = writeJSONList(gh.File, access)
+	} else {
+		err = writeTeamsCSV(gh.File, access)
+	}
+	if err != nil {
+		fmt.Printf("Error writing teams was %+v\n", err)
+		os.Exit(exitError)
+	}
+	fmt.Printf("Wrote %d team permissions to %s\n", len(access), gh.File)
+}
+
+// collectTeams takes a pointer to ghAPIClient and returns the permission each team of the
+// current org has on each of its repos along with the team's members
+func collectTeams(g *ghAPIClient) ([]ghTeamAccess, error) {
+	teams := ghTeams{}
+	err := getTeams(g, &teams)
+	if err != nil {
+		return nil, err
+	}
+
+	var access []ghTeamAccess
+	for _, t := range teams {
+		members := ghMembers{}
+		err = getTeamMembers(g, t.Slug, &members)
+		if err != nil {
+			return nil, err
+		}
+		repos := ghTeamRepos{}
+		err = getTeamRepos(g, t.Slug, &repos)
+		if err != nil {
+			return nil, err
+		}
+
+		team := ghTeamAccess{Org: g.Org, Team: t.Name, Slug: t.Slug, Privacy: t.Privacy, Members: []string{}}
+		if t.Parent != nil {
+			team.Parent = t.Parent.Slug
+		}
+		for _, v := range members {
+			team.Members = append(team.Members, v.Login)
+		}
+		if len(repos) == 0 {
+			access = append(access, team)
+			continue
+		}
+		for k := range repos {
+			a := team
+			a.Repo = repos[k].Name
+			a.Permission = teamPermission(repos, k)
+			access = append(access, a)
+		}
+	}
+
+	return access, nil
+}
+
+// teamPermission takes the repos of a team and the index of one of them and returns the team's
+// role on the repo, working it out from the permissions if Github didn't provide the role
+func teamPermission(r ghTeamRepos, k int) string {
+	switch {
+	case len(r[k].RoleName) > 0:
+		return r[k].RoleName
+	case r[k].Permissions.Admin:
+		return "admin"
+	case r[k].Permissions.Maintain:
+		return "maintain"
+	case r[k].Permissions.Push:
+		return "write"
+	case r[k].Permissions.Triage:
+		return "triage"
+	}
+
+	return "read"
+}
+
+// writeTeamsCSV takes a file name and the team permissions of one or more orgs and writes
+// them out as a CSV
+func writeTeamsCSV(f string, access []ghTeamAccess) error {
+	header := []string{
+		"Org",         // e.g. my-github-org
+		"Team",        // e.g. Platform Team
+		"Slug",        // e.g. platform-team
+		"Privacy",     // closed or secret
+		"Parent Team", // Slug of the parent team, empty for top level teams
+		"Members",     // e.g. jdoe, asmith
+		"Repo",        // e.g. my-repo, empty for teams without any repos
+		"Permission",  // e.g. admin, maintain, write, triage, read or a custom role
+	}
+
+	// Add a line for each team and repo
+	var rows [][]string
+	for _, v := range access {
+		rows = append(rows, []string{v.Org, v.Team, v.Slug, v.Privacy, v.Parent, strings.Join(v.Members, ", "), v.Repo, v.Permission})
+	}
+
+	return writeCSVRows(f, header, rows)
+}
+
+// getTeams takes pointers to ghAPIClient and ghTeams and retrieves all the teams of the
+// current org
+// see https://docs.github.com/en/rest/teams/teams#list-teams
+func getTeams(g *ghAPIClient, t *ghTeams) error {
+	return getAllPages(g, "/orgs/"+g.Org+"/teams", "Org teams", func(page []byte) error {
+		tempTeams := ghTeams{}
+		err := json.Unmarshal(page, &tempTeams)
+		*t = append(*t, tempTeams...)
+		return err
+	})
+}
+
+// getTeamMembers takes a pointer to ghAPIClient, a team slug and a pointer to ghMembers and
+// retrieves all the members of the team, including those of its child teams
+// see https://docs.github.com/en/rest/teams/members#list-team-members
+func getTeamMembers(g *ghAPIClient, slug string, m *ghMembers) error {
+	return getAllPages(g, "/orgs/"+g.Org+"/teams/"+slug+"/members", "Team members", func(page []byte) error {
+		tempMembers := ghMembers{}
+		err := json.Unmarshal(page, &tempMembers)
+		*m = append(*m, tempMembers...)
+		return err
+	})
+}
+
+// getTeamRepos takes a pointer to ghAPIClient, a team slug and a pointer to ghTeamRepos and
+// retrieves all the repos the team has access to
+// see https://docs.github.com/en/rest/teams/teams#list-team-repositories
+func getTeamRepos(g *ghAPIClient, slug string, r *ghTeamRepos) error {
+	return getAllPages(g, "/orgs/"+g.Org+"/teams/"+slug+"/repos", "Team repos", func(page []byte) error {
+		tempRepos := ghTeamRepos{}
+		err := json.Unmarshal(page, &tempRepos)
+		*r = append(*r, tempRepos...)
+		return err
+	})
+}
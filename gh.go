@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"strconv"
 	"strings"
 	"time"
@@ -20,8 +22,64 @@ type ghAPIClient struct {
 	Header     string
 	Token      string
 	Org        string
+	Orgs       []string
+	Enterprise string
+	User       string
+	OwnerType  string
 	File       string
 	Meta       ghMeta
+	Format     string
+	Columns    []csvColumn
+	Layout     string
+	// Directory to add a JSON snapshot of each run to, empty to skip snapshots
+	SnapshotDir string
+	Stream      *json.Encoder
+	// Look up admin emails beyond the public profile email
+	ResolveEmails bool
+	// Collect the SAML SSO identities of org members
+	SSO bool
+	// Collect pending invitations, flagging those at least StaleInviteDays old
+	Invitations     bool
+	StaleInviteDays int
+	// Policy rules to check the collected data against, nil to skip, and the violations found
+	Policy     *policy
+	Violations []policyViolation
+	// Look for repos without an active admin outside of the org owners
+	Orphans bool
+	// Employee roster to reconcile accounts with access against, nil to skip
+	Roster *roster
+	// Where every change made through the Github API is logged, nil to skip logging
+	MutationLog io.Writer
+	// Open, update and close tracking issues for policy violations, in each repo or in
+	// the central IssuesRepo (org/repo) when it's set. Changes are only printed unless
+	// IssuesApply is set
+	Issues      bool
+	IssuesRepo  string
+	IssuesApply bool
+	// Where to email the report and post a summary of it, and the violations report to include
+	Delivery       delivery
+	ViolationsFile string
+	// Recommend a lower role for admins with no write activity in this many days, 0 to skip
+	LeastPrivilegeDays int
+	// Files to write the violations to as JUnit XML and SARIF, empty to skip
+	JUnitFile string
+	SARIFFile string
+	// Number of repos to collect collaborators for at a time
+	Concurrency int
+	// Repos to leave out of the report
+	Filter repoFilter
+}
+
+// Struct to hold where the Github API is and how to authenticate with it
+type ghConnection struct {
+	// Base URL of the REST API e.g. https://github.example.com/api/v3, empty for github.com
+	APIURL string
+	// How to get the token, one of the auth methods, empty for authToken
+	Auth string
+	// Environmental variable holding the token for authToken, empty for GHTOKEN
+	TokenEnv string
+	// File holding the token for authTokenFile
+	TokenFile string
 }
 
 // Struct to hold meta data while retrieving paginated data
@@ -32,24 +90,60 @@ type ghMeta struct {
 	linkHeader string
 }
 
-// setupClient takes a pointer to ghAPIClient, validates that
-// the required environmental variable 'GHTOKEN' exists and, if so,
-// creates a ghAPIClient with default values set
-func setupClient(g *ghAPIClient, o string, f string) error {
-	// Setup the necessary config from the environment
-	t, present := os.LookupEnv("GHTOKEN")
-	if !present {
-		// If GHTOKEN isn't set, error out
-		return errors.New("Required environmental variable 'GHTOKEN' not found")
-	}
+// Types of Github account which can own the repos being reported on
+const (
+	ownerOrg  = "org"
+	ownerUser = "user"
+	ownerSelf = "self"
+)
+
+// Value for the user argument which selects the authenticated user's own account
+const selfUser = "@me"
+
+// Login Github gives to content left behind by deleted accounts
+const ghostUser = "ghost"
 
+// Default base URL of the Github API and environmental variable holding the token
+const (
+	defaultAPIURL   = "https://api.github.com"
+	defaultTokenEnv = "GHTOKEN"
+)
+
+// Methods of getting the token to authenticate with the Github API
+const (
+	// Read the token from an environmental variable
+	authToken = "token"
+	// Read the token from a file e.g. a mounted secret
+	authTokenFile = "token-file"
+	// Ask the Github CLI for the token it's logged in with
+	authGhCLI = "gh-cli"
+)
+
+// Supported methods of getting the token
+var authMethods = []string{authToken, authTokenFile, authGhCLI}
+
+// setupClient takes a pointer to ghAPIClient and a ghConnection, gets the
+// token using the connection's auth method (by default from the environmental
+// variable 'GHTOKEN') and, if found, creates a ghAPIClient with default values
+// set. The org string may hold a comma-separated list of Github organizations
+func setupClient(g *ghAPIClient, conn ghConnection, o string, e string, usr string, f string) error {
 	// Setup base URL
-	u, err := url.Parse("https://api.github.com")
+	api := conn.APIURL
+	if len(api) == 0 {
+		api = defaultAPIURL
+	}
+	u, err := url.Parse(strings.TrimSuffix(api, "/"))
 	if err != nil {
 		fmt.Printf("Error parsing the Github API URL was %+v\n", err)
 		return err
 	}
 
+	// Setup the necessary config from the environment
+	t, err := ghToken(conn, u)
+	if err != nil {
+		return err
+	}
+
 	// Setup HttpClient
 	c := &http.Client{}
 
@@ -58,16 +152,62 @@ func setupClient(g *ghAPIClient, o string, f string) error {
 	g.HttpClient = c
 	g.Header = "Authorization"
 	g.Token = "token " + t
-	g.Org = o
+	g.Orgs = splitOrgs(o)
+	if len(g.Orgs) > 0 {
+		g.Org = g.Orgs[0]
+	}
+	g.Enterprise = e
+	g.User = strings.TrimSpace(usr)
+	g.OwnerType = ownerOrg
 	g.File = f
 	g.Meta.pagination = false
 	g.Meta.nextPage = 0
 	g.Meta.lastPage = 0
 	g.Meta.linkHeader = ""
+	g.Concurrency = 1
 
 	return nil
 }
 
+// ghToken takes a ghConnection and the base URL of the API and returns the token to
+// authenticate with using the connection's auth method
+func ghToken(c ghConnection, api *url.URL) (string, error) {
+	switch c.Auth {
+	case authTokenFile:
+		b, err := ioutil.ReadFile(c.TokenFile)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Problem reading token file was: %v", err))
+		}
+		if len(strings.TrimSpace(string(b))) == 0 {
+			return "", errors.New(fmt.Sprintf("Token file %s is empty", c.TokenFile))
+		}
+		return strings.TrimSpace(string(b)), nil
+	case authGhCLI:
+		// The Github CLI knows github.com by its web host rather than the API host
+		host := api.Host
+		if strings.EqualFold(host, "api.github.com") {
+			host = "github.com"
+		}
+		out, err := exec.Command("gh", "auth", "token", "--hostname", host).Output()
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Problem getting a token from the Github CLI for %s was: %v", host, err))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	env := c.TokenEnv
+	if len(env) == 0 {
+		env = defaultTokenEnv
+	}
+	t, present := os.LookupEnv(env)
+	if !present {
+		// If the token isn't set, error out
+		return "", errors.New(fmt.Sprintf("Required environmental variable '%s' not found", env))
+	}
+
+	return t, nil
+}
+
 // addURL takes a pointer to a ghAPIClient and a string
 // which holds a URI to append on the existing URL in
 // ghAPIClient to produce a full URL for an API call
@@ -88,6 +228,75 @@ func addURI(g *ghAPIClient, u string) error {
 // Set the organization for the ghAPIClient
 func setOrg(g *ghAPIClient, o string) {
 	g.Org = o
+	g.OwnerType = ownerOrg
+}
+
+// Set a user account as the owner of the repos for the ghAPIClient. The login
+// for the authenticated user is filled in by getOrgInfo when u is selfUser
+func setUser(g *ghAPIClient, u string) {
+	if strings.Compare(u, selfUser) == 0 {
+		g.Org = ""
+		g.OwnerType = ownerSelf
+		return
+	}
+	g.Org = u
+	g.OwnerType = ownerUser
+}
+
+// ownerURI takes a pointer to ghAPIClient and returns the URI for the
+// account that owns the repos being reported on
+// see https://docs.github.com/en/rest/orgs/orgs#get-an-organization
+// see https://docs.github.com/en/rest/users/users#get-a-user
+// see https://docs.github.com/en/rest/users/users#get-the-authenticated-user
+func ownerURI(g *ghAPIClient) string {
+	switch g.OwnerType {
+	case ownerUser:
+		return "/users/" + g.Org
+	case ownerSelf:
+		return "/user"
+	}
+
+	return "/orgs/" + g.Org
+}
+
+// reposURI takes a pointer to ghAPIClient and a page number and returns the URI
+// to list the repos of the account being reported on. A page of 0 leaves off the
+// page query parameter. For the authenticated user, only repos they own are listed
+// see https://docs.github.com/en/rest/repos/repos#list-organization-repositories
+// see https://docs.github.com/en/rest/repos/repos#list-repositories-for-a-user
+// see https://docs.github.com/en/rest/repos/repos#list-repositories-for-the-authenticated-user
+func reposURI(g *ghAPIClient, p int) string {
+	if g.OwnerType == ownerSelf {
+		u := "/user/repos?affiliation=owner"
+		if p > 0 {
+			u += "&page=" + strconv.Itoa(p)
+		}
+		return u
+	}
+
+	u := ownerURI(g) + "/repos"
+	if p > 0 {
+		u += "?page=" + strconv.Itoa(p)
+	}
+	return u
+}
+
+// splitOrgs takes a comma-separated list of Github organizations and
+// returns them as a slice with any whitespace and empty entries removed.
+// Org logins aren't case sensitive so only the first of Acme,acme is kept
+func splitOrgs(o string) []string {
+	var orgs []string
+	seen := make(map[string]bool)
+	for _, v := range strings.Split(o, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 || seen[strings.ToLower(v)] {
+			continue
+		}
+		seen[strings.ToLower(v)] = true
+		orgs = append(orgs, v)
+	}
+
+	return orgs
 }
 
 // resetMeta takes a pointer to ghAPIClient and resets the
@@ -100,8 +309,118 @@ func resetMeta(g *ghAPIClient) {
 }
 
 // Takes a pointer to ghAPIClient and generates a CSV of the
-// appropriate Github organization
+// appropriate Github organization(s)
 func generateGhCSV(g *ghAPIClient) error {
+	// Discover the orgs in the Github Enterprise account if one was provided
+	if len(g.Enterprise) > 0 {
+		entTime := time.Now()
+		err := getEnterpriseOrgs(g)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem discovering Enterprise orgs was: %v", err))
+		}
+		fmt.Printf("Get enterprise orgs done in %v\n", time.Since(entTime))
+	}
+
+	// Stream repo records to the output file as they're collected for NDJSON
+	if g.Format == formatNDJSON {
+		fi, err := os.Create(g.File)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem creating NDJSON file was: %v", err))
+		}
+		defer fi.Close()
+		g.Stream = json.NewEncoder(fi)
+	}
+
+	// Collect the data for each org
+	var allOrgs []ghOrgData
+	for _, o := range g.Orgs {
+		fmt.Printf("Collecting data for org %s\n", o)
+		setOrg(g, o)
+		oData := ghOrgData{}
+		err := collectOrgData(g, &oData)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem collecting data for org %s was: %v", o, err))
+		}
+		allOrgs = append(allOrgs, oData)
+	}
+
+	// Collect the data for a user account if one was provided
+	if len(g.User) > 0 {
+		fmt.Printf("Collecting data for user %s\n", g.User)
+		setUser(g, g.User)
+		uData := ghOrgData{}
+		err := collectOrgData(g, &uData)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem collecting data for user %s was: %v", g.User, err))
+		}
+		allOrgs = append(allOrgs, uData)
+	}
+
+	// Write out the report in the requested format
+	err := writeReport(g, allOrgs)
+	if err != nil {
+		return err
+	}
+
+	// Check the collected data against the policy rules
+	if g.Policy != nil {
+		policyTime := time.Now()
+		g.Violations = evaluatePolicy(g.Policy, allOrgs)
+		f, err := writeViolations(g, g.Violations)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing policy violations was: %v", err))
+		}
+		g.ViolationsFile = f
+		fmt.Printf("Found %d policy violations, written to %s\n", len(g.Violations), f)
+		if len(g.JUnitFile) > 0 {
+			err = writeJUnit(g.JUnitFile, g.Policy, allOrgs, g.Violations)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Problem writing JUnit file was: %v", err))
+			}
+		}
+		if len(g.SARIFFile) > 0 {
+			err = writeSARIF(g.SARIFFile, g.Policy, g.Violations)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Problem writing SARIF file was: %v", err))
+			}
+		}
+		if g.Issues {
+			err = syncIssues(g, allOrgs)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Problem updating tracking issues was: %v", err))
+			}
+		}
+		fmt.Printf("Check policy done in %v\n", time.Since(policyTime))
+	}
+
+	// Add this run to the snapshot store, keeping the previous run to summarize what changed
+	prev := ""
+	if len(g.SnapshotDir) > 0 {
+		prev = latestSnapshot(g.SnapshotDir)
+		snapTime := time.Now()
+		f, err := writeSnapshot(g.SnapshotDir, allOrgs, g.Orphans)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing snapshot was: %v", err))
+		}
+		fmt.Printf("Write snapshot %s done in %v\n", f, time.Since(snapTime))
+	}
+
+	// Email the report and post a summary
+	if g.Delivery.enabled() {
+		deliverTime := time.Now()
+		err = deliverReport(g, allOrgs, prev)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deliver report done in %v\n", time.Since(deliverTime))
+	}
+
+	return nil
+}
+
+// collectOrgData takes pointers to ghAPIClient and ghOrgData and gathers the org info, repos,
+// collaborators, admins and admin user details for the org currently set in ghAPIClient
+func collectOrgData(g *ghAPIClient, d *ghOrgData) error {
 	// Start the timer
 	orgTime := time.Now()
 	// Get info on the provided Github org
@@ -117,6 +436,9 @@ func generateGhCSV(g *ghAPIClient) error {
 	if len(oInfo) > 1 {
 		return errors.New("Multiple Github organizations returned, which makes no sense. Exiting...")
 	}
+	if len(oInfo) == 1 {
+		d.Info = oInfo[0]
+	}
 
 	// Use the Github org info to retrieve a list of repos for that GH org
 	repoTime := time.Now()
@@ -128,29 +450,37 @@ func generateGhCSV(g *ghAPIClient) error {
 	resetMeta(g)
 	fmt.Printf("Get org repos done in %v\n", time.Since(repoTime))
 
+	// Leave out the repos which don't match the filters
+	if g.Filter.enabled() {
+		all := len(oRepos)
+		oRepos = filterRepos(g.Filter, oRepos)
+		fmt.Printf("Filters left %d of %d repos\n", len(oRepos), all)
+	}
+
+	// Get SAML SSO identities up front so streamed repo records include them
+	if g.SSO && g.OwnerType == ownerOrg {
+		ssoTime := time.Now()
+		err = getSSO(g, d)
+		if err != nil {
+			return err
+		}
+		resetMeta(g)
+		fmt.Printf("Get SSO identities done in %v\n", time.Since(ssoTime))
+	}
+
 	// For each repo in the GH org, get a list of collaborators to pull out those with admin roles
 	collabTime := time.Now()
 	rCollab := make(map[string]ghCollaborators)
 	rAdmins := make(map[string]ghCollaborators)
-	for k := range oRepos {
-		// Use Go routines to pull down collaborators and admins per repo
-		collabErr := make(chan error, 1)
-		go func() {
-			// Gather the collaborators and admins for the org's current repo
-			tempCollab := ghCollaborators{}
-			tempAdmins := ghCollaborators{}
-			err = getCollabs(g, oRepos[k].CollaboratorsURL, oRepos[k].Name, &tempCollab, &tempAdmins, g.Meta)
-			collabErr <- err
-
-			// Store collected values
-			rCollab[oRepos[k].Name] = tempCollab
-			rAdmins[oRepos[k].Name] = tempAdmins
-
-		}()
-		err = <-collabErr
-		if err != nil {
-			return errors.New(fmt.Sprintf("Problem preparing Collaborators request was: %v", err))
-		}
+	nameLookup := make(map[string]ghNameDetail)
+	d.Org = g.Org
+	d.Repos = oRepos
+	d.Collabs = rCollab
+	d.Admins = rAdmins
+	d.Names = nameLookup
+	err = collectCollabs(g, d)
+	if err != nil {
+		return err
 	}
 	resetMeta(g)
 	fmt.Printf("Get repo collabs done in %v\n", time.Since(collabTime))
@@ -160,7 +490,6 @@ func generateGhCSV(g *ghAPIClient) error {
 
 	// For each collaborator with an admin role, determine their name (human one vs GH login name aka Github username)
 	userTime := time.Now()
-	nameLookup := make(map[string]ghNameDetail)
 	for k := range oRepos {
 		err = getUserDetail(g, oRepos[k].Name, rAdmins, nameLookup)
 		if err != nil {
@@ -170,18 +499,162 @@ func generateGhCSV(g *ghAPIClient) error {
 	resetMeta(g)
 	fmt.Printf("Get user detail done in %v\n", time.Since(userTime))
 
-	// Generate the CSV and write it out.
-	csvTime := time.Now()
-	err = writeCSV(g.File, &oRepos, rAdmins, nameLookup)
-	if err != nil {
-		return errors.New(fmt.Sprintf("Problem writing CSV file was: %v", err))
+	// Look further for emails of admins who don't have a public one on their profile,
+	// which has already been done repo by repo when streaming
+	if g.ResolveEmails && g.Stream == nil {
+		emailTime := time.Now()
+		err = resolveEmails(g, oRepos, rAdmins, nameLookup)
+		if err != nil {
+			return err
+		}
+		resetMeta(g)
+		fmt.Printf("Resolve admin emails done in %v\n", time.Since(emailTime))
+	}
+
+	// Add where each collaborator's access comes from for the long CSV layout, policy rules and
+	// least-privilege recommendations, which can only downgrade direct access
+	if g.Layout == layoutLong || (g.Policy != nil && g.Policy.needsAccess()) || g.LeastPrivilegeDays > 0 {
+		accessTime := time.Now()
+		err = collectAccessSources(g, d)
+		if err != nil {
+			return err
+		}
+		resetMeta(g)
+		fmt.Printf("Get access sources done in %v\n", time.Since(accessTime))
+	}
+
+	// Add the org owners to tell repos only they administer, user accounts have no owners
+	if g.Orphans && g.OwnerType == ownerOrg {
+		ownerTime := time.Now()
+		err = collectOwners(g, d)
+		if err != nil {
+			return err
+		}
+		resetMeta(g)
+		fmt.Printf("Get org owners done in %v\n", time.Since(ownerTime))
+	} else if g.Orphans {
+		d.Owners = make(map[string]bool)
+	}
+
+	// Look for admins who don't write to their repos, after the owners are known so they're skipped
+	if g.LeastPrivilegeDays > 0 {
+		activityTime := time.Now()
+		err = collectActivity(g, d)
+		if err != nil {
+			return err
+		}
+		resetMeta(g)
+		fmt.Printf("Get admin activity done in %v\n", time.Since(activityTime))
+	}
+
+	// Add default branch protection for policy rules
+	if g.Policy != nil && g.Policy.needsProtection() {
+		protectTime := time.Now()
+		err = collectBranchProtection(g, d)
+		if err != nil {
+			return err
+		}
+		resetMeta(g)
+		fmt.Printf("Get branch protection done in %v\n", time.Since(protectTime))
+	}
+
+	// Add pending org and repo invitations
+	if g.Invitations {
+		inviteTime := time.Now()
+		err = collectInvitations(g, d)
+		if err != nil {
+			return err
+		}
+		resetMeta(g)
+		fmt.Printf("Get invitations done in %v\n", time.Since(inviteTime))
 	}
 
-	fmt.Printf("Get user detail done in %v\n", time.Since(csvTime))
+	// Add SAML SSO identities to the admins and find members without one
+	if g.SSO && g.OwnerType == ownerOrg {
+		ssoTime := time.Now()
+		err = collectSSO(g, d)
+		if err != nil {
+			return err
+		}
+		resetMeta(g)
+		fmt.Printf("Get SSO members done in %v\n", time.Since(ssoTime))
+	}
+
+	// Match the admins and members against the employee roster, after SSO so its emails can be used
+	if g.Roster != nil {
+		rosterTime := time.Now()
+		err = collectRoster(g, d)
+		if err != nil {
+			return err
+		}
+		resetMeta(g)
+		fmt.Printf("Reconcile roster done in %v\n", time.Since(rosterTime))
+	}
 
 	return nil
 }
 
+// Struct to hold the collaborators and admins collected for one repo
+type collabResult struct {
+	k       int
+	collabs ghCollaborators
+	admins  ghCollaborators
+	err     error
+}
+
+// collectCollabs takes pointers to ghAPIClient and ghOrgData and gathers the collaborators and
+// admins of each repo, working on up to Concurrency repos at a time
+func collectCollabs(g *ghAPIClient, d *ghOrgData) error {
+	workers := g.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Hand out the repos to the workers
+	jobs := make(chan int)
+	results := make(chan collabResult)
+	go func() {
+		for k := range d.Repos {
+			jobs <- k
+		}
+		close(jobs)
+	}()
+	for i := 0; i < workers; i++ {
+		// Each worker needs its own copy of the client as it holds the URL of the request
+		wg := *g
+		resetMeta(&wg)
+		go func(wg ghAPIClient) {
+			for k := range jobs {
+				r := collabResult{k: k}
+				r.err = getCollabs(&wg, d.Repos[k].CollaboratorsURL, d.Repos[k].Name, &r.collabs, &r.admins, wg.Meta)
+				results <- r
+			}
+		}(wg)
+	}
+
+	// Store each repo's results as they arrive, waiting for every repo before returning
+	var err error
+	for range d.Repos {
+		r := <-results
+		if err != nil {
+			continue
+		}
+		if r.err != nil {
+			err = errors.New(fmt.Sprintf("Problem preparing Collaborators request was: %v", r.err))
+			continue
+		}
+		d.Collabs[d.Repos[r.k].Name] = r.collabs
+		d.Admins[d.Repos[r.k].Name] = r.admins
+
+		// Write out the repo as soon as its admins are known when streaming
+		if g.Stream != nil {
+			err = streamRepo(g, d, r.k)
+		}
+	}
+
+	return err
+}
+
 // pagedResults takes in a http Reponse struct and looks for a "Link" header
 // which Github API uses to determine if the response has been paginated.
 // Returns true if there are multiple pages of results and the next and last
@@ -312,11 +785,12 @@ func parsePage(u *url.URL) (int, error) {
 }
 
 // getOrgInfo takes pointers to ghAPIClient and ghOrgInfo and retrieves the Github org's repos
-// (based on the Org field of ghAPIClient) to fill the ghOrgInfo struct
+// (based on the Org field of ghAPIClient) to fill the ghOrgInfo struct. User accounts return
+// a subset of the org fields so the same struct is used for them
 func getOrgInfo(g *ghAPIClient, oInfo *[]ghOrgInfo) error {
-	// Add the URI for the Get organization call
+	// Add the URI for the Get organization (or user) call
 	// see https://docs.github.com/en/rest/orgs/orgs#get-an-organization
-	addURI(g, "/orgs/"+g.Org)
+	addURI(g, ownerURI(g))
 
 	// Setup meta data struct and temp data struct
 	tempOrg := ghOrgInfo{}
@@ -360,6 +834,11 @@ func getOrgInfo(g *ghAPIClient, oInfo *[]ghOrgInfo) error {
 	// Append the data collected so far
 	*oInfo = append(*oInfo, tempOrg)
 
+	// Fill in the login of the authenticated user for later calls
+	if g.OwnerType == ownerSelf {
+		g.Org = tempOrg.Login
+	}
+
 	// Check for pagination
 	err = pagedResults(&g.Meta)
 	if err != nil {
@@ -368,7 +847,7 @@ func getOrgInfo(g *ghAPIClient, oInfo *[]ghOrgInfo) error {
 
 	if g.Meta.pagination {
 		// Construct the new URL for the next page of results
-		addURI(g, "/orgs/"+g.Org+"?page="+strconv.Itoa(g.Meta.nextPage))
+		addURI(g, ownerURI(g)+"?page="+strconv.Itoa(g.Meta.nextPage))
 		if g.Meta.nextPage <= g.Meta.lastPage {
 			// Increament the next page
 			err := getOrgInfo(g, oInfo)
@@ -387,7 +866,7 @@ func getOrgRepos(g *ghAPIClient, oRepos *ghRepoInfo) error {
 	// Add the URI for the Get organization call
 	// see https://docs.github.com/en/rest/orgs/orgs#get-an-organization
 	if !g.Meta.pagination {
-		addURI(g, "/orgs/"+g.Org+"/repos")
+		addURI(g, reposURI(g, 0))
 	}
 
 	// Setup meta data struct and temp data struct
@@ -442,7 +921,7 @@ func getOrgRepos(g *ghAPIClient, oRepos *ghRepoInfo) error {
 
 	if g.Meta.pagination {
 		// Construct the new URL for the next page of results
-		addURI(g, "/orgs/"+g.Org+"/repos?page="+strconv.Itoa(g.Meta.nextPage))
+		addURI(g, reposURI(g, g.Meta.nextPage))
 		if g.Meta.nextPage <= g.Meta.lastPage {
 			// Increament the next page
 			err := getOrgRepos(g, oRepos)
@@ -495,6 +974,12 @@ func getCollabs(g *ghAPIClient, raw string, repo string, c *ghCollaborators, a *
 		return errors.New(fmt.Sprintf("Problem reading response body was: %v", err))
 	}
 
+	// Only the owner can list the collaborators of a user's repos, so carry on without them
+	if resp.StatusCode == http.StatusForbidden && g.OwnerType == ownerUser {
+		fmt.Printf("Unable to get collaborators for %s/%s, only the owner can list them, leaving them empty\n", g.Org, repo)
+		return nil
+	}
+
 	// Check the response code
 	if resp.StatusCode != 200 {
 		return errors.New(fmt.Sprintf("API response code for Repo collaborators was: %v", resp.StatusCode))
@@ -573,10 +1058,13 @@ func getSingleUser(g *ghAPIClient, l string, lu map[string]ghNameDetail) error {
 		return nil
 	}
 
-	// Make the User API call to get the details for this user
+	// Make the User API call to get the details for this user. A failed lookup e.g. from
+	// rate limiting only loses the user's name so warn and leave it blank rather than
+	// failing the whole report, recording the blank so the lookup isn't repeated
 	err := userFromAPI(g, l, lu)
-	if exists {
-		return errors.New(fmt.Sprintf("Problem calling API for user data for %+v was: %v", l, err))
+	if err != nil {
+		fmt.Printf("Unable to get user details for %s, leaving the name blank: %v\n", l, err)
+		lu[l] = ghNameDetail{}
 	}
 
 	return nil
@@ -613,9 +1101,13 @@ func userFromAPI(g *ghAPIClient, l string, lu map[string]ghNameDetail) error {
 		return errors.New(fmt.Sprintf("Problem reading response body was: %v", err))
 	}
 
-	// Check the response code
+	// Check the response code, deleted accounts are no longer found
+	if resp.StatusCode == 404 {
+		lu[l] = ghNameDetail{Departed: true}
+		return nil
+	}
 	if resp.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("API response code for Org Info was: %v", resp.StatusCode))
+		return errors.New(fmt.Sprintf("API response code for User was: %v", resp.StatusCode))
 	}
 
 	// Unmarshall data to struct
@@ -626,8 +1118,13 @@ func userFromAPI(g *ghAPIClient, l string, lu map[string]ghNameDetail) error {
 
 	// Add details to the lookup map
 	lu[l] = ghNameDetail{
-		Name:  tempUser.Name,
-		Email: tempUser.Email,
+		Name:      tempUser.Name,
+		Email:     tempUser.Email,
+		Suspended: tempUser.SuspendedAt != nil,
+		Departed:  strings.EqualFold(tempUser.Login, ghostUser),
+	}
+	if len(tempUser.Email) > 0 {
+		setEmail(lu, l, tempUser.Email, emailProfile)
 	}
 
 	// Check for pagination
@@ -638,3 +1135,104 @@ func userFromAPI(g *ghAPIClient, l string, lu map[string]ghNameDetail) error {
 
 	return nil
 }
+
+// getJSON takes a pointer to ghAPIClient, a URI, a short description of what is being
+// requested (used in error messages) and a pointer to the struct to fill. It makes a GET
+// request to the Github API, saves the link header for pagination and unmarshals the response
+func getJSON(g *ghAPIClient, u string, what string, d interface{}) error {
+	// Add the URI for the call
+	err := addURI(g, u)
+	if err != nil {
+		return err
+	}
+
+	// Setup the request
+	rawResp := ""
+	req, err := http.NewRequest(http.MethodGet, g.FullURL.String(), strings.NewReader(rawResp))
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem preparing Request was: %v", err))
+	}
+	req.Header.Add("content-type", "application/vnd.github+json")
+	req.Header.Add(g.Header, g.Token)
+
+	// Send the request
+	resp, err := g.HttpClient.Do(req)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem sending Request was: %v", err))
+	}
+	defer resp.Body.Close()
+
+	// Read the response body
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem reading response body was: %v", err))
+	}
+
+	// Check the response code
+	if resp.StatusCode != 200 {
+		return errors.New(fmt.Sprintf("API response code for %s was: %v", what, resp.StatusCode))
+	}
+
+	// Save the link header
+	g.Meta.linkHeader = resp.Header.Get("link")
+
+	// Unmarshall data to struct
+	err = json.Unmarshal(body, d)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem unmarshalling JSON was: %v", err))
+	}
+
+	return nil
+}
+
+// Returned by the appendFn passed to getAllPages to stop before the last page
+var errLastPage = errors.New("last page wanted")
+
+// getAllPages takes a pointer to ghAPIClient, a URI, a short description of what is being
+// requested (used in error messages) and a function which unmarshals one page of results and
+// appends them to the caller's slice. It calls getJSON for each page of results, following the
+// pagination in the link header until the last page or until appendFn returns errLastPage
+func getAllPages(g *ghAPIClient, u string, what string, appendFn func(page []byte) error) error {
+	defer resetMeta(g)
+	for {
+		page := json.RawMessage{}
+		err := getJSON(g, u, what, &page)
+		if err != nil {
+			return err
+		}
+		err = appendFn(page)
+		if err == errLastPage {
+			return nil
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem unmarshalling JSON was: %v", err))
+		}
+
+		// Check for pagination
+		err = pagedResults(&g.Meta)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem determining pagination was: %v", err))
+		}
+		if !g.Meta.pagination || g.Meta.nextPage > g.Meta.lastPage {
+			return nil
+		}
+		u, err = pageURI(u, g.Meta.nextPage)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// pageURI takes a URI and a page number and returns the URI with its page query parameter
+// set to the page, keeping any other query parameters
+func pageURI(u string, p int) (string, error) {
+	x, err := url.Parse(u)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Problem parsing URI %s was: %v", u, err))
+	}
+	q := x.Query()
+	q.Set("page", strconv.Itoa(p))
+	x.RawQuery = q.Encode()
+
+	return x.String(), nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// testClient returns a ghAPIClient which sends its requests to the test server
+func testClient(t *testing.T, srv *httptest.Server) *ghAPIClient {
+	t.Setenv("GHTOKEN", "test-token")
+	g := ghAPIClient{}
+	err := setupClient(&g, ghConnection{APIURL: srv.URL}, "acme", "", "", "test.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &g
+}
+
+func TestPageURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		page int
+		want string
+	}{
+		{"/orgs/acme/teams", 2, "/orgs/acme/teams?page=2"},
+		{"/orgs/acme/members?role=admin", 3, "/orgs/acme/members?page=3&role=admin"},
+		{"/orgs/acme/members?page=2&role=admin", 3, "/orgs/acme/members?page=3&role=admin"},
+	}
+	for _, tc := range tests {
+		got, err := pageURI(tc.uri, tc.page)
+		if err != nil {
+			t.Fatalf("pageURI(%q, %d) returned %v", tc.uri, tc.page, err)
+		}
+		if got != tc.want {
+			t.Errorf("pageURI(%q, %d) = %q, want %q", tc.uri, tc.page, got, tc.want)
+		}
+	}
+}
+
+func TestPagedResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    string
+		want    ghMeta
+		wantErr bool
+	}{
+		{
+			name: "no link header",
+			want: ghMeta{},
+		},
+		{
+			name: "first page",
+			link: `<https://api.github.com/organizations/123/repos?page=2>; rel="next", <https://api.github.com/organizations/123/repos?page=4>; rel="last"`,
+			want: ghMeta{pagination: true, nextPage: 2, lastPage: 4},
+		},
+		{
+			name: "middle page",
+			link: `<https://api.github.com/organizations/123/repos?page=1>; rel="prev", <https://api.github.com/organizations/123/repos?page=3>; rel="next", <https://api.github.com/organizations/123/repos?page=4>; rel="last", <https://api.github.com/organizations/123/repos?page=1>; rel="first"`,
+			want: ghMeta{pagination: true, nextPage: 3, lastPage: 4},
+		},
+		{
+			name: "last page",
+			link: `<https://api.github.com/organizations/123/repos?page=3>; rel="prev", <https://api.github.com/organizations/123/repos?page=1>; rel="first"`,
+			want: ghMeta{},
+		},
+		{
+			name: "other query parameters",
+			link: `<https://api.github.com/orgs/acme/members?role=admin&page=2>; rel="next", <https://api.github.com/orgs/acme/members?role=admin&page=5>; rel="last"`,
+			want: ghMeta{pagination: true, nextPage: 2, lastPage: 5},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := ghMeta{linkHeader: tc.link}
+			err := pagedResults(&m)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("pagedResults returned %v", err)
+			}
+			m.linkHeader = ""
+			if m != tc.want {
+				t.Errorf("pagedResults = %+v, want %+v", m, tc.want)
+			}
+		})
+	}
+}
+
+// pagedServer returns a test server which serves the logins of the pages provided for
+// /orgs/acme/members, keeping the role query parameter in its link headers
+func pagedServer(pages [][]string) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/orgs/acme/members" {
+			http.NotFound(w, r)
+			return
+		}
+		p := 1
+		if len(r.URL.Query().Get("page")) > 0 {
+			p, _ = strconv.Atoi(r.URL.Query().Get("page"))
+		}
+		if p < len(pages) {
+			w.Header().Set("link", fmt.Sprintf(`<%s/orgs/acme/members?role=%s&page=%d>; rel="next", <%s/orgs/acme/members?role=%s&page=%d>; rel="last"`,
+				srv.URL, r.URL.Query().Get("role"), p+1, srv.URL, r.URL.Query().Get("role"), len(pages)))
+		}
+		var m []map[string]string
+		for _, v := range pages[p-1] {
+			m = append(m, map[string]string{"login": v + "-" + r.URL.Query().Get("role")})
+		}
+		json.NewEncoder(w).Encode(m)
+	}))
+
+	return srv
+}
+
+func TestGetAllPages(t *testing.T) {
+	srv := pagedServer([][]string{{"a", "b"}, {"c"}, {"d"}})
+	defer srv.Close()
+	g := testClient(t, srv)
+
+	m := ghMembers{}
+	err := getMembers(g, "admin", &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range m {
+		got = append(got, v.Login)
+	}
+	want := []string{"a-admin", "b-admin", "c-admin", "d-admin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getMembers = %v, want %v", got, want)
+	}
+	if g.Meta.pagination || g.Meta.nextPage != 0 {
+		t.Errorf("getAllPages left pagination meta %+v", g.Meta)
+	}
+
+	// Stopping early with errLastPage
+	var pages int
+	err = getAllPages(g, "/orgs/acme/members", "Org members", func(page []byte) error {
+		pages++
+		return errLastPage
+	})
+	if err != nil || pages != 1 {
+		t.Errorf("getAllPages stopped after %d pages with %v, want 1 page and no error", pages, err)
+	}
+
+	// Errors from the API are returned
+	err = getAllPages(g, "/orgs/other/members", "Org members", func(page []byte) error {
+		return nil
+	})
+	if err == nil {
+		t.Error("getAllPages of a missing URI returned no error")
+	}
+}
+
+func TestGetAllPagesBadJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"message":"not a list"}`)
+	}))
+	defer srv.Close()
+	g := testClient(t, srv)
+
+	m := ghMembers{}
+	err := getMembers(g, "", &m)
+	if err == nil {
+		t.Error("getMembers of an object returned no error")
+	}
+}
+
+func TestSplitOrgs(t *testing.T) {
+	tests := []struct {
+		orgs string
+		want []string
+	}{
+		{"", nil},
+		{"acme", []string{"acme"}},
+		{" acme , widgets ,", []string{"acme", "widgets"}},
+		{"acme,acme", []string{"acme"}},
+		{"Acme,widgets,acme,WIDGETS", []string{"Acme", "widgets"}},
+	}
+	for _, tc := range tests {
+		got := splitOrgs(tc.orgs)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitOrgs(%q) = %q, want %q", tc.orgs, got, tc.want)
+		}
+	}
+}
+
+func TestGetSingleUser(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/jdoe":
+			fmt.Fprint(w, `{"login":"jdoe","name":"Jane Doe","email":"jane@example.com"}`)
+		case "/users/limited":
+			w.WriteHeader(http.StatusForbidden)
+		case "/users/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	g := testClient(t, srv)
+
+	tests := []struct {
+		login string
+		want  ghNameDetail
+	}{
+		{"jdoe", ghNameDetail{Name: "Jane Doe", Email: "jane@example.com", EmailSource: emailProfile}},
+		{"gone", ghNameDetail{Departed: true}},
+		{"limited", ghNameDetail{}},
+		{"broken", ghNameDetail{}},
+	}
+	lu := make(map[string]ghNameDetail)
+	for _, tc := range tests {
+		err := getSingleUser(g, tc.login, lu)
+		if err != nil {
+			t.Errorf("getSingleUser(%q) returned %v", tc.login, err)
+		}
+		got, exists := lu[tc.login]
+		if !exists {
+			t.Errorf("getSingleUser(%q) didn't record the user", tc.login)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("getSingleUser(%q) recorded %+v, want %+v", tc.login, got, tc.want)
+		}
+	}
+}
+
+func TestCollectCollabsForbidden(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/jdoe/api/collaborators", "/repos/acme/api/collaborators":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		owner   string
+		user    bool
+		wantErr bool
+	}{
+		{name: "another user's repos", owner: "jdoe", user: true},
+		{name: "org repos", owner: "acme", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := testClient(t, srv)
+			if tc.user {
+				setUser(g, tc.owner)
+			}
+			d := ghOrgData{
+				Repos:   ghRepoInfo{{Name: "api", CollaboratorsURL: srv.URL + "/repos/" + tc.owner + "/api/collaborators{/collaborator}"}},
+				Collabs: make(map[string]ghCollaborators),
+				Admins:  make(map[string]ghCollaborators),
+			}
+
+			err := collectCollabs(g, &d)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("collectCollabs returned error %v, want error %v", err, tc.wantErr)
+			}
+			if len(d.Collabs["api"]) > 0 || len(d.Admins["api"]) > 0 {
+				t.Errorf("collectCollabs found collaborators %v and admins %v, want none", d.Collabs["api"], d.Admins["api"])
+			}
+		})
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/mtesauro/gh-org-tools
 
 go 1.17
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.15
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"html/template"
+	"os"
+	"sort"
+	"time"
+)
+
+// Struct for the data used to fill in the HTML report template
+type htmlReport struct {
+	GeneratedAt string
+	Orgs        []htmlOrg
+}
+
+// Struct for a single Github org in the HTML report
+type htmlOrg struct {
+	Org       string
+	Repos     []ndjsonRepo
+	Summary   []htmlCount
+	Languages []htmlCount
+}
+
+// Struct for a single labeled count in the summary section of the HTML report
+type htmlCount struct {
+	Label string
+	Count int
+}
+
+// writeHTML takes a file name and the data collected for one or more orgs and writes a
+// single, self-contained HTML report with a summary and a sortable, filterable repo table
+func writeHTML(f string, orgs []ghOrgData) error {
+	// Create the HTML file
+	fi, err := os.Create(f)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	// Build the report
+	rpt := htmlReport{GeneratedAt: time.Now().UTC().Format(time.RFC1123)}
+	for _, o := range orgs {
+		rpt.Orgs = append(rpt.Orgs, newHTMLOrg(o))
+	}
+
+	t, err := template.New("report").Funcs(template.FuncMap{
+		"date": htmlDate,
+	}).Parse(htmlTemplate)
+	if err != nil {
+		return err
+	}
+
+	return t.Execute(fi, rpt)
+}
+
+// newHTMLOrg converts the data collected for an org to the struct used in the HTML report
+func newHTMLOrg(o ghOrgData) htmlOrg {
+	ho := htmlOrg{Org: o.Org}
+
+	// Count repos by visibility, fork and archived status plus language
+	var public, private, internal, forks, archived, noAdmins int
+	langs := make(map[string]int)
+	for k, r := range o.Repos {
+		ho.Repos = append(ho.Repos, ndjsonRepo{Org: o.Org, jsonRepo: newJSONRepo(o, k)})
+		switch r.Visibility {
+		case "public":
+			public++
+		case "internal":
+			internal++
+		default:
+			private++
+		}
+		if r.Fork {
+			forks++
+		}
+		if r.Archived {
+			archived++
+		}
+		if len(o.Admins[r.Name]) == 0 {
+			noAdmins++
+		}
+		l := r.Language
+		if len(l) == 0 {
+			l = "None"
+		}
+		langs[l]++
+	}
+	ho.Summary = []htmlCount{
+		{"Repos", len(o.Repos)},
+		{"Public", public},
+		{"Private", private},
+		{"Internal", internal},
+		{"Forks", forks},
+		{"Archived", archived},
+		{"Without Admins", noAdmins},
+	}
+
+	// Most used languages first
+	for l, c := range langs {
+		ho.Languages = append(ho.Languages, htmlCount{l, c})
+	}
+	sort.Slice(ho.Languages, func(i, j int) bool {
+		if ho.Languages[i].Count == ho.Languages[j].Count {
+			return ho.Languages[i].Label < ho.Languages[j].Label
+		}
+		return ho.Languages[i].Count > ho.Languages[j].Count
+	})
+
+	return ho
+}
+
+// htmlDate formats a time for the HTML report, leaving unset times empty
+func htmlDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format("2006-01-02 15:04")
+}
+
+// Template for the HTML report with the CSS and JavaScript embedded so it's a single file
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Github org report{{range .Orgs}} - {{.Org}}{{end}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292f; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; border-bottom: 1px solid #d0d7de; padding-bottom: .3em; margin-top: 2em; }
+.meta { color: #57606a; }
+.cards { display: flex; flex-wrap: wrap; gap: .8em; margin: 1em 0; }
+.card { border: 1px solid #d0d7de; border-radius: 6px; padding: .6em 1em; min-width: 6em; }
+.card .n { font-size: 1.5em; font-weight: 600; }
+.card .l { color: #57606a; font-size: .85em; }
+.langs span { display: inline-block; background: #f6f8fa; border: 1px solid #d0d7de; border-radius: 2em; padding: .1em .7em; margin: .2em; font-size: .85em; }
+input.filter { padding: .4em; width: 30em; max-width: 100%; margin: .5em 0; border: 1px solid #d0d7de; border-radius: 6px; }
+table { border-collapse: collapse; width: 100%; font-size: .9em; }
+th, td { border: 1px solid #d0d7de; padding: .35em .6em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; cursor: pointer; user-select: none; position: sticky; top: 0; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+tr:nth-child(even) td { background: #fbfbfc; }
+.public { color: #cf222e; font-weight: 600; }
+.none { color: #cf222e; }
+details summary { cursor: pointer; }
+ul.admins { margin: .3em 0 0 1em; padding: 0; }
+</style>
+</head>
+<body>
+<h1>Github org report</h1>
+<p class="meta">Generated {{.GeneratedAt}}</p>
+{{range $i, $o := .Orgs}}
+<h2>{{$o.Org}}</h2>
+<div class="cards">
+{{range $o.Summary}}<div class="card"><div class="n">{{.Count}}</div><div class="l">{{.Label}}</div></div>
+{{end}}</div>
+<div class="langs">{{range $o.Languages}}<span>{{.Label}}: {{.Count}}</span>{{end}}</div>
+<input class="filter" type="search" placeholder="Filter repos..." data-table="repos-{{$i}}">
+<table id="repos-{{$i}}">
+<thead><tr>
+<th>Repo</th><th>Description</th><th>Visibility</th><th>Fork</th><th>Archived</th><th>Language</th><th>Last Update</th><th>Last Push</th><th>Admins</th>
+</tr></thead>
+<tbody>
+{{range $o.Repos}}<tr>
+<td>{{if .HTMLURL}}<a href="{{.HTMLURL}}">{{.FullName}}</a>{{else}}{{.FullName}}{{end}}</td>
+<td>{{.Description}}</td>
+<td{{if eq .Visibility "public"}} class="public"{{end}}>{{.Visibility}}</td>
+<td>{{.Fork}}</td>
+<td>{{.Archived}}</td>
+<td>{{.Language}}</td>
+<td>{{date .UpdatedAt}}</td>
+<td>{{date .PushedAt}}</td>
+<td data-sort="{{len .Admins}}">{{if .Admins}}<details><summary>{{len .Admins}} admin(s)</summary><ul class="admins">
+{{range .Admins}}<li>{{.Login}}{{if .Name}} ({{.Name}}){{end}}{{if .Email}} &lt;{{.Email}}&gt;{{end}}{{if .SSONameID}} SSO: {{.SSONameID}}{{end}}</li>
+{{end}}</ul></details>{{else}}<span class="none">none</span>{{end}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+{{end}}
+<script>
+// Filter table rows by the text typed into the filter box above each table
+document.querySelectorAll("input.filter").forEach(function (input) {
+  input.addEventListener("input", function () {
+    var q = input.value.toLowerCase();
+    document.querySelectorAll("#" + input.dataset.table + " tbody tr").forEach(function (tr) {
+      tr.style.display = tr.textContent.toLowerCase().indexOf(q) === -1 ? "none" : "";
+    });
+  });
+});
+// Sort a table by the clicked column, numbers sort numerically
+document.querySelectorAll("th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var table = th.closest("table"), tbody = table.tBodies[0];
+    var col = Array.prototype.indexOf.call(th.parentNode.children, th);
+    var asc = !th.classList.contains("asc");
+    th.parentNode.querySelectorAll("th").forEach(function (h) { h.classList.remove("asc", "desc"); });
+    th.classList.add(asc ? "asc" : "desc");
+    var val = function (tr) {
+      var td = tr.children[col];
+      return td.dataset.sort !== undefined ? td.dataset.sort : td.textContent.trim().toLowerCase();
+    };
+    var rows = Array.prototype.slice.call(tbody.rows);
+    rows.sort(function (a, b) {
+      var x = val(a), y = val(b), nx = parseFloat(x), ny = parseFloat(y);
+      var c = (!isNaN(nx) && !isNaN(ny)) ? nx - ny : x.localeCompare(y);
+      return asc ? c : -c;
+    });
+    rows.forEach(function (tr) { tbody.appendChild(tr); });
+  });
+});
+</script>
+</body>
+</html>
+`
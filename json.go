@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Struct for the JSON report covering one or more Github orgs
+type jsonReport struct {
+	GeneratedAt    time.Time         `json:"generated_at"`
+	Orgs           []jsonOrg         `json:"orgs"`
+	CrossOrgAdmins []ghCrossOrgAdmin `json:"cross_org_admins,omitempty"`
+	OrphanedRepos  []ghOrphanedRepo  `json:"orphaned_repos,omitempty"`
+	// Accounts with access that aren't on the employee roster or have left
+	RosterExceptions []ghRosterException `json:"roster_exceptions,omitempty"`
+	// Admins recommended a lower role
+	LeastPrivilege []ghRecommendation `json:"least_privilege,omitempty"`
+}
+
+// Struct for a single Github org (or user account) in the JSON report
+type jsonOrg struct {
+	Org         string     `json:"org"`
+	Info        ghOrgInfo  `json:"info"`
+	Repos       []jsonRepo `json:"repos"`
+	Invitations []ghInvite `json:"invitations,omitempty"`
+	SSOUnlinked []string   `json:"sso_unlinked,omitempty"`
+}
+
+// Struct for a single repo in the JSON report
+type jsonRepo struct {
+	FullName      string      `json:"full_name"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	HTMLURL       string      `json:"html_url"`
+	Private       bool        `json:"private"`
+	Fork          bool        `json:"fork"`
+	Archived      bool        `json:"archived"`
+	Visibility    string      `json:"visibility"`
+	DefaultBranch string      `json:"default_branch"`
+	Language      string      `json:"language"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	PushedAt      time.Time   `json:"pushed_at"`
+	Admins        []jsonAdmin `json:"admins"`
+}
+
+// Struct for a single repo admin in the JSON report
+type jsonAdmin struct {
+	Login       string `json:"login"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	EmailSource string `json:"email_source,omitempty"`
+	Permission  string `json:"permission"`
+	SSOStatus   string `json:"sso_status,omitempty"`
+	SSONameID   string `json:"sso_name_id,omitempty"`
+	SSOEmail    string `json:"sso_email,omitempty"`
+	Manager     string `json:"manager,omitempty"`
+}
+
+// Struct for a single line of the NDJSON output, a repo with the org it belongs to
+type ndjsonRepo struct {
+	Org string `json:"org"`
+	jsonRepo
+}
+
+// writeJSON takes a file name, the data collected for one or more orgs and whether orphaned
+// repos were asked for and writes the report as an indented JSON document
+func writeJSON(f string, orgs []ghOrgData, orphans bool) error {
+	// Create the JSON file
+	fi, err := os.Create(f)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	// Build the report
+	rpt := jsonReport{GeneratedAt: time.Now().UTC()}
+	for _, o := range orgs {
+		rpt.Orgs = append(rpt.Orgs, newJSONOrg(o))
+	}
+	if len(orgs) > 1 {
+		rpt.CrossOrgAdmins = crossOrgAdmins(orgs)
+	}
+	if orphans {
+		rpt.OrphanedRepos = orphanedRepos(orgs)
+	}
+	rpt.RosterExceptions = rosterExceptions(orgs)
+	for _, o := range orgs {
+		rpt.LeastPrivilege = append(rpt.LeastPrivilege, o.Recommendations...)
+	}
+
+	// Write it out
+	enc := json.NewEncoder(fi)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rpt)
+}
+
+// newJSONOrg converts the data collected for an org to the struct used in the JSON report
+func newJSONOrg(o ghOrgData) jsonOrg {
+	jo := jsonOrg{
+		Org:         o.Org,
+		Info:        o.Info,
+		Repos:       []jsonRepo{},
+		Invitations: o.Invitations,
+		SSOUnlinked: o.SSOUnlinked,
+	}
+	for k := range o.Repos {
+		jo.Repos = append(jo.Repos, newJSONRepo(o, k))
+	}
+
+	return jo
+}
+
+// newJSONRepo converts the repo at index k of the data collected for an org
+// to the struct used in the JSON report
+func newJSONRepo(o ghOrgData, k int) jsonRepo {
+	v := o.Repos[k]
+	jr := jsonRepo{
+		FullName:      v.FullName,
+		Name:          v.Name,
+		Description:   v.Description,
+		HTMLURL:       v.HTMLURL,
+		Private:       v.Private,
+		Fork:          v.Fork,
+		Archived:      v.Archived,
+		Visibility:    v.Visibility,
+		DefaultBranch: v.DefaultBranch,
+		Language:      v.Language,
+		CreatedAt:     v.CreatedAt,
+		UpdatedAt:     v.UpdatedAt,
+		PushedAt:      v.PushedAt,
+		Admins:        []jsonAdmin{},
+	}
+	for _, a := range o.Admins[v.Name] {
+		d := o.Names[a.Login]
+		jr.Admins = append(jr.Admins, jsonAdmin{
+			Login:       a.Login,
+			Name:        d.Name,
+			Email:       d.Email,
+			EmailSource: d.EmailSource,
+			Permission:  a.RoleName,
+			SSOStatus:   d.SSOStatus,
+			SSONameID:   d.SSONameID,
+			SSOEmail:    d.SSOEmail,
+			Manager:     d.Manager,
+		})
+	}
+
+	return jr
+}
+
+// streamRepo takes a pointer to ghAPIClient and ghOrgData plus the index of a repo whose
+// collaborators have been collected. It looks up the details of that repo's admins and
+// writes the repo as a single line of JSON to the Stream field of ghAPIClient
+func streamRepo(g *ghAPIClient, d *ghOrgData, k int) error {
+	// Get the admin details for just this repo, re-using those already looked up
+	err := getUserDetail(g, d.Repos[k].Name, d.Admins, d.Names)
+	if err != nil {
+		return err
+	}
+	if g.ResolveEmails {
+		err = resolveEmails(g, d.Repos[k:k+1], d.Admins, d.Names)
+		if err != nil {
+			return err
+		}
+	}
+	applySSO(d)
+	resetMeta(g)
+
+	return g.Stream.Encode(ndjsonRepo{Org: d.Org, jsonRepo: newJSONRepo(*d, k)})
+}
+
+// writeJSONList takes a file name and a slice of records e.g. org members and writes
+// them out as an indented JSON array
+func writeJSONList(f string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(f, append(b, '\n'), 0644)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	d := ghOrgData{
+		Org:   "acme",
+		Repos: ghRepoInfo{{Name: "api", FullName: "acme/api", Visibility: "private", DefaultBranch: "main"}, {Name: "web", FullName: "acme/web"}},
+		Admins: map[string]ghCollaborators{
+			"api": {{Login: "jdoe", RoleName: "admin"}, {Login: "dependabot[bot]", Type: "Bot", RoleName: "admin"}},
+		},
+		Names: map[string]ghNameDetail{
+			"jdoe": {Name: "Doe, Jane (JD)", Email: "jane@example.com", EmailSource: emailProfile, SSOStatus: ssoLinked, SSONameID: "jane@example.com"},
+		},
+	}
+
+	f := filepath.Join(t.TempDir(), "report.json")
+	err := writeJSON(f, []ghOrgData{d}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := jsonReport{}
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Orgs) != 1 || got.Orgs[0].Org != "acme" || len(got.Orgs[0].Repos) != 2 {
+		t.Fatalf("writeJSON wrote orgs %+v, want acme with 2 repos", got.Orgs)
+	}
+	api := got.Orgs[0].Repos[0]
+	if api.FullName != "acme/api" || api.Visibility != "private" || api.DefaultBranch != "main" {
+		t.Errorf("writeJSON wrote repo %+v, want acme/api", api)
+	}
+
+	// Names with commas and parentheses are kept whole rather than needing to be parsed
+	want := []jsonAdmin{
+		{Login: "jdoe", Name: "Doe, Jane (JD)", Email: "jane@example.com", EmailSource: emailProfile, Permission: "admin",
+			SSOStatus: ssoLinked, SSONameID: "jane@example.com"},
+		{Login: "dependabot[bot]", Permission: "admin"},
+	}
+	if !reflect.DeepEqual(api.Admins, want) {
+		t.Errorf("writeJSON wrote admins\n%+v\nwant\n%+v", api.Admins, want)
+	}
+
+	// Repos without admins have an empty list rather than null
+	if got.Orgs[0].Repos[1].Admins == nil {
+		t.Errorf("writeJSON wrote null admins for acme/web, want an empty list")
+	}
+	if len(got.CrossOrgAdmins) > 0 {
+		t.Errorf("writeJSON wrote cross org admins %+v for a single org", got.CrossOrgAdmins)
+	}
+
+	// Orphaned repos are included when asked for even if the org owners weren't collected
+	wantOrphans := []ghOrphanedRepo{{Org: "acme", Repo: "web", Reason: orphanNoAdmins}}
+	if !reflect.DeepEqual(got.OrphanedRepos, wantOrphans) {
+		t.Errorf("writeJSON wrote orphaned repos %+v, want %+v", got.OrphanedRepos, wantOrphans)
+	}
+}
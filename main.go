@@ -4,43 +4,385 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 )
 
 var (
 	ver = "v0.1.0"
 )
 
+// Struct to hold the arguments of the commands which report on repos
+type reportArgs struct {
+	csvName, org, ent, user, format, columns, layout, snapDir string
+	resolveEmails, sso, invites                               bool
+	staleDays                                                 int
+	rosterFile                                                string
+	// Only used by the audit command
+	policyFile, junit, sarif, issuesRepo, mutationLog string
+	check, orphans, issues, issuesApply               bool
+	leastPrivDays                                     int
+	// Where to deliver the report
+	smtpHost, smtpFrom, smtpTo, smtpUser, webhook string
+	// Config file, how to connect to Github and which repos to collect
+	configFile                    string
+	conn                          ghConnection
+	concurrency                   int
+	visibility, include, exclude  string
+	excludeArchived, excludeForks bool
+}
+
 func main() {
-	// Setup command-line arguments
-	var csvName, org string
-	var version, help, v, h bool
-	flag.StringVar(&csvName, "csv", "Findings-example.csv", "Provide the name of the CSV to create")
-	flag.StringVar(&org, "org", "", "Provide the name of the Github organization to report on")
-	flag.BoolVar(&version, "version", false, "Print the version and exit")
-	flag.BoolVar(&v, "v", false, "Print the version and exit")
-	flag.BoolVar(&help, "help", false, "Print the help message and exit")
-	flag.BoolVar(&h, "h", false, "Print the help message and exit")
-	flag.Parse()
-
-	// Handle command-line arguments
-	handleStarndarArgs(h, help, v, version)
+	if len(os.Args) < 2 {
+		fmt.Println("Error: No command provided, printing help...")
+		printUsage()
+		os.Exit(exitError)
+	}
+
+	switch strings.TrimLeft(os.Args[1], "-") {
+	case "help", "h":
+		if len(os.Args) > 2 {
+			if _, exists := findCommand(os.Args[2]); exists {
+				runCommand(os.Args[2], []string{"-help"})
+			}
+		}
+		printUsage()
+		os.Exit(exitClean)
+	case "version", "v":
+		fmt.Printf("ghorg2csv version %s\n", ver)
+		os.Exit(exitClean)
+	}
+
+	// Earlier versions had no commands, so run flags without a command as an audit which
+	// takes every flag they did
+	if strings.HasPrefix(os.Args[1], "-") {
+		fmt.Println("Note: Running without a command is deprecated, use ghorg2csv repos or ghorg2csv audit")
+		auditCommand(os.Args[1:])
+		os.Exit(exitClean)
+	}
+
+	if _, exists := findCommand(os.Args[1]); !exists {
+		fmt.Printf("Error: Unknown command '%s', printing help...\n", os.Args[1])
+		printUsage()
+		os.Exit(exitError)
+	}
+	runCommand(os.Args[1], os.Args[2:])
+	os.Exit(exitClean)
+}
+
+// runCommand takes the name of a command and its arguments and runs the command
+func runCommand(n string, args []string) {
+	switch n {
+	// Handle commands which collect data from Github
+	case "repos":
+		reposCommand(args)
+	case "members":
+		membersCommand(args)
+	case "teams":
+		teamsCommand(args)
+	case "audit":
+		auditCommand(args)
+	// Handle commands which don't collect data from Github
+	case "diff":
+		diffCommand(args)
+	case "snapshots":
+		snapshotsCommand(args)
+	case "review":
+		reviewCommand(args)
+	// Handle commands which change things on Github
+	case "remediate":
+		remediateCommand(args)
+	}
+}
+
+// reposCommand handles the repos command which reports on the repos of orgs, an enterprise
+// or a user and their admins
+func reposCommand(args []string) {
+	fs := newFlagSet("repos")
+	a := reportArgs{}
+	reposFlags(fs, &a)
+	fs.Parse(args)
+	configArgs(fs)
+	noArgs(fs)
+
+	runReport(&a)
+}
+
+// auditCommand handles the audit command which reports on repos like the repos command then
+// checks them against a policy and for orphaned repos, roster exceptions and excess access
+func auditCommand(args []string) {
+	fs := newFlagSet("audit")
+	a := reportArgs{}
+	auditFlags(fs, &a)
+	fs.Parse(args)
+	configArgs(fs)
+	noArgs(fs)
+
+	gh := runReport(&a)
+
+	// Fail the check when the policy found violations
+	if a.check && len(gh.Violations) > 0 {
+		fmt.Printf("Check failed with %d policy violations\n", len(gh.Violations))
+		os.Exit(exitViolations)
+	}
+}
+
+// reposFlags defines the flags of the repos command
+func reposFlags(fs *flag.FlagSet, a *reportArgs) {
+	configFlags(fs, &a.configFile)
+	targetFlags(fs, a, true)
+	connectionFlags(fs, &a.conn)
+	outputFlags(fs, a)
+	filterFlags(fs, a)
+	collectFlags(fs, a)
+	deliveryFlags(fs, a)
+}
+
+// auditFlags defines the flags of the audit command, which are those of the repos command
+// plus the policy and hygiene checks
+func auditFlags(fs *flag.FlagSet, a *reportArgs) {
+	reposFlags(fs, a)
+	fs.StringVar(&a.policyFile, "policy", "", "Provide a YAML policy file of rules to check each repo against. Violations are\n"+
+		"written to a report named like org-info-violations.csv with the rule ID, repo,\n"+
+		"severity and message. Supported checks are license, max-direct-admins,\n"+
+		"no-outside-admin and default-branch-protected")
+	fs.BoolVar(&a.check, "check", false, "Run as a CI check of the -policy rules, exiting with 1 if violations are found")
+	fs.StringVar(&a.junit, "junit", "", "Provide a file to write the -policy results to as JUnit XML with a test suite\n"+
+		"per rule and a test case per repo")
+	fs.StringVar(&a.sarif, "sarif", "", "Provide a file to write the -policy violations to as SARIF 2.1.0")
+	fs.BoolVar(&a.issues, "issues", false, "Print the tracking issues labeled ghorg2csv-policy that would be opened in\n"+
+		"each repo with -policy violations, updated or closed once the violations are\n"+
+		"resolved. Add -issues-apply to make the changes on Github")
+	fs.StringVar(&a.issuesRepo, "issues-repo", "", "Provide a repo e.g. my-org/tracking to open one tracking issue per repo with\n"+
+		"violations in, instead of in the repos themselves")
+	fs.BoolVar(&a.issuesApply, "issues-apply", false, "Open, update and close the -issues tracking issues on Github instead of only\n"+
+		"printing the changes")
+	fs.StringVar(&a.mutationLog, "mutation-log", "ghorg2csv-mutations.ndjson", "Provide the file every change made on Github is logged to as a line of JSON")
+	fs.BoolVar(&a.orphans, "orphans", false, "Write a CSV named like org-info-orphaned-repos.csv of the repos whose only\n"+
+		"admins are org owners, whose admins are all suspended or deleted accounts, or\n"+
+		"that have no admins once bots are excluded")
+	fs.IntVar(&a.leastPrivDays, "least-privilege-days", 0, "Write a CSV named like org-info-least-privilege.csv of the repo admins with no\n"+
+		"commits, pushes or pull requests in this many days, with a suggested role\n"+
+		"(triage if they review or work on issues, otherwise read) and the evidence.\n"+
+		"Github keeps pushes and pull requests for 90 days at most, the evidence gives\n"+
+		"the window actually checked. Org owners, bots and admins through a team or the\n"+
+		"org are skipped")
+}
+
+// targetFlags defines the flags which select the accounts to collect data for, including
+// -user when the command supports user accounts
+func targetFlags(fs *flag.FlagSet, a *reportArgs, user bool) {
+	fs.StringVar(&a.org, "org", "", "Provide the name of the Github organization or a comma-separated list of\n"+
+		"organizations e.g. \"org1,org2\"")
+	fs.StringVar(&a.ent, "enterprise", "", "Provide the slug of a Github Enterprise account to report on every org in that\n"+
+		"account, may be used instead of -org")
+	if user {
+		fs.StringVar(&a.user, "user", "", "Provide the login of a Github user account to report on, may be used instead\n"+
+			"of -org. Use @me for the account that owns GHTOKEN which includes private\n"+
+			"repos. Only public repos are listed for other users")
+	}
+}
+
+// connectionFlags defines the flags which set where the Github API is and how to authenticate with it
+func connectionFlags(fs *flag.FlagSet, c *ghConnection) {
+	fs.StringVar(&c.APIURL, "api-url", defaultAPIURL, "Provide the base URL of the Github API e.g. https://github.example.com/api/v3\n"+
+		"for Github Enterprise Server")
+	fs.StringVar(&c.Auth, "auth", authToken, "Provide how to get the token to authenticate with:\n"+
+		"  token      - read it from the environmental variable named by -token-env\n"+
+		"  token-file - read it from the file named by -token-file\n"+
+		"  gh-cli     - ask the Github CLI for the token it's logged in with")
+	fs.StringVar(&c.TokenEnv, "token-env", defaultTokenEnv, "Provide the environmental variable holding the token for -auth token")
+	fs.StringVar(&c.TokenFile, "token-file", "", "Provide the file holding the token for -auth token-file")
+}
 
+// filterFlags defines the flags which leave repos out of the report
+func filterFlags(fs *flag.FlagSet, a *reportArgs) {
+	fs.StringVar(&a.visibility, "visibility", "", "Provide a comma-separated list of the visibilities of repos to report on,\n"+
+		"from public, private and internal")
+	fs.BoolVar(&a.excludeArchived, "exclude-archived", false, "Leave archived repos out of the report")
+	fs.BoolVar(&a.excludeForks, "exclude-forks", false, "Leave forks out of the report")
+	fs.StringVar(&a.include, "include", "", "Provide a comma-separated list of repo name patterns e.g. \"api-*,web-?\" to\n"+
+		"only report on the repos matching one of them")
+	fs.StringVar(&a.exclude, "exclude", "", "Provide a comma-separated list of repo name patterns to leave out of the report")
+}
+
+// outputFlags defines the flags which control the report written
+func outputFlags(fs *flag.FlagSet, a *reportArgs) {
+	fs.StringVar(&a.csvName, "csv", "Findings-example.csv", "Provide the name of the CSV to create")
+	fs.StringVar(&a.format, "format", formatCSV, "Provide the output format: csv, json, ndjson, xlsx, html or sqlite\n"+
+		"  json   - one file including invitations, SSO and cross-org data\n"+
+		"  ndjson - one line per repo written as soon as its admins are known so\n"+
+		"           partial results survive a failed run\n"+
+		"  xlsx   - Excel workbook with Summary, Repos, Repo Admins and Org Settings sheets\n"+
+		"  html   - single file report with an org summary and a sortable, filterable\n"+
+		"           repo table including admin details\n"+
+		"  sqlite - database with orgs, repos, users, collaborators and invitations\n"+
+		"           tables plus a repo_admins view")
+	fs.StringVar(&a.columns, "columns", "", "Provide a comma-separated list of columns to select and order the columns of\n"+
+		"the CSV. Any repo field can be used by its API name with nested fields joined\n"+
+		"by a dot e.g. license.spdx_id, plus org, short_description, admins,\n"+
+		"admin_count and collaborator_count. Add :Label to a column to set its header.\n"+
+		"The default is:\n"+strings.Join(defaultColumns, ","))
+	fs.StringVar(&a.layout, "layout", layoutWide, "Provide the CSV layout: wide for one row per repo with all admins in one\n"+
+		"column or long for one row per repo, user, permission and source. Source is\n"+
+		"direct, team-or-org or outside-collaborator")
+	fs.StringVar(&a.snapDir, "snapshots", "", "Provide a directory to add a dated JSON snapshot of this run to.\n"+
+		"List and chart the snapshots with: ghorg2csv snapshots -help")
+}
+
+// collectFlags defines the flags which collect extra data for the report
+func collectFlags(fs *flag.FlagSet, a *reportArgs) {
+	fs.BoolVar(&a.resolveEmails, "resolve-emails", false, "Look up emails for admins without a public email on their profile using the\n"+
+		"org's verified domains then their most recent commits")
+	fs.BoolVar(&a.sso, "sso", false, "Add the SAML SSO identity of each admin to the report and write a CSV named\n"+
+		"like org-info-sso-unlinked.csv of org members with no linked identity.\n"+
+		"Requires a token from an org owner")
+	fs.BoolVar(&a.invites, "invitations", false, "Write a CSV named like org-info-invitations.csv listing pending org and repo\n"+
+		"invitations with the inviter, invitee, role and age")
+	fs.IntVar(&a.staleDays, "stale-invite-days", 7, "Flag invitations at least this many days old as stale")
+	fs.IntVar(&a.concurrency, "concurrency", 1, "Provide the number of repos to collect collaborators for at a time")
+	fs.StringVar(&a.rosterFile, "roster", "", "Provide an employee roster CSV with columns for the Github login and/or email,\n"+
+		"status and (optionally) manager. Writes a CSV named like\n"+
+		"org-info-roster-exceptions.csv of the accounts not on the roster or with a\n"+
+		"terminated status, and adds each admin's manager to the report. Include the\n"+
+		"Github login to match every account, emails are only known for admins and,\n"+
+		"with -sso, members with a linked identity. Other accounts not matched by\n"+
+		"login are reported as unmatched-no-email")
+}
+
+// deliveryFlags defines the flags which email the report and post a summary of it
+func deliveryFlags(fs *flag.FlagSet, a *reportArgs) {
+	fs.StringVar(&a.smtpHost, "smtp-host", "", "Provide an SMTP server as host:port to email the report through")
+	fs.StringVar(&a.smtpFrom, "smtp-from", "", "Provide the address the report is emailed from")
+	fs.StringVar(&a.smtpTo, "smtp-to", "", "Provide a comma-separated list of addresses to email the report to")
+	fs.StringVar(&a.smtpUser, "smtp-user", "", "Provide the SMTP login, the password is read from SMTP_PASSWORD")
+	fs.StringVar(&a.webhook, "webhook", "", "Provide a Slack or Teams incoming webhook URL to post a summary to")
+}
+
+// Ensure no arguments are left over after the flags of a command
+func noArgs(fs *flag.FlagSet) {
+	if fs.NArg() > 0 {
+		fmt.Printf("Error: Unexpected argument '%s', printing help...\n", fs.Arg(0))
+		fs.Usage()
+		os.Exit(exitError)
+	}
+}
+
+// runReport takes the arguments of the repos or audit command, checks them, then collects the
+// data from Github and writes the report, returning the API client used
+func runReport(a *reportArgs) *ghAPIClient {
 	// Check required arguments
-	requiredArgs(csvName, org)
+	requiredArgs(a.csvName, a.org, a.ent, a.user)
+	formatArgs(a.format)
+	cols := columnArgs(a.columns)
+	layoutArgs(a.layout)
+	rules := policyArgs(a.policyFile)
+	checkArgs(rules, a.check, a.junit, a.sarif)
+	issuesArgs(rules, a.issues, a.issuesRepo, a.issuesApply)
+	send := deliveryArgs(a.smtpHost, a.smtpFrom, a.smtpTo, a.smtpUser, a.webhook)
+	employees := rosterArgs(a.rosterFile)
+	connectionArgs(a.conn)
+	concurrencyArgs(a.concurrency)
+	filter := filterArgs(a.visibility, a.excludeArchived, a.excludeForks, a.include, a.exclude)
 
 	// Setup an API client to talk to Github's API
 	gh := ghAPIClient{}
-	err := setupClient(&gh, org, csvName)
+	err := setupClient(&gh, a.conn, a.org, a.ent, a.user, a.csvName)
 	if err != nil {
 		fmt.Printf("Error setting up the API client was %+v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+	gh.Format = a.format
+	gh.Columns = cols
+	gh.Layout = a.layout
+	gh.SnapshotDir = a.snapDir
+	gh.Policy = rules
+	gh.JUnitFile = a.junit
+	gh.SARIFFile = a.sarif
+	if a.format != formatCSV {
+		gh.File = reportName(a.csvName, a.format)
+	}
+	gh.ResolveEmails = a.resolveEmails
+	gh.SSO = a.sso
+	gh.Invitations = a.invites
+	gh.StaleInviteDays = a.staleDays
+	gh.Orphans = a.orphans
+	gh.Roster = employees
+	gh.Issues = a.issues || len(a.issuesRepo) > 0 || a.issuesApply
+	gh.IssuesRepo = a.issuesRepo
+	gh.IssuesApply = a.issuesApply
+	gh.Delivery = send
+	gh.LeastPrivilegeDays = a.leastPrivDays
+	gh.Concurrency = a.concurrency
+	gh.Filter = filter
+	if gh.IssuesApply {
+		logFile, err := os.OpenFile(a.mutationLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Printf("Error opening the mutation log was %+v\n", err)
+			os.Exit(exitError)
+		}
+		defer logFile.Close()
+		gh.MutationLog = logFile
 	}
 
-	// Create a CSV of Github org information
+	// Create a report of Github org information
 	err = generateGhCSV(&gh)
 	if err != nil {
-		fmt.Printf("Error occured while generating CSV\n%+v\n", err)
-		os.Exit(1)
+		fmt.Printf("Error occured while generating report\n%+v\n", err)
+		os.Exit(exitError)
+	}
+
+	return &gh
+}
+
+// listFlags defines the flags of the commands which list things in orgs, with def as the
+// default name of the CSV
+func listFlags(fs *flag.FlagSet, a *reportArgs, def string) {
+	configFlags(fs, &a.configFile)
+	targetFlags(fs, a, false)
+	connectionFlags(fs, &a.conn)
+	fs.StringVar(&a.csvName, "csv", def, "Provide the name of the CSV to create")
+	fs.StringVar(&a.format, "format", formatCSV, "Provide the output format, either csv or json. For json, the file is written to\n"+
+		"the -csv name with .json in place of .csv")
+}
+
+// listClient takes the arguments of the members or teams command, checks them and returns an
+// API client for the orgs provided, including every org of an enterprise
+func listClient(a *reportArgs) *ghAPIClient {
+	// Check required arguments
+	if len(splitOrgs(a.org)) == 0 && len(a.ent) == 0 {
+		fmt.Println("Please provide a Github org with the -org argument or an enterprise with -enterprise")
+		os.Exit(exitError)
+	}
+	requiredArgs(a.csvName, a.org, a.ent, "")
+	if a.format != formatCSV && a.format != formatJSON {
+		fmt.Printf("ERROR: Unsupported output format '%s', use csv or json\n", a.format)
+		os.Exit(exitError)
+	}
+	connectionArgs(a.conn)
+
+	// Setup an API client to talk to Github's API
+	gh := ghAPIClient{}
+	err := setupClient(&gh, a.conn, a.org, a.ent, "", a.csvName)
+	if err != nil {
+		fmt.Printf("Error setting up the API client was %+v\n", err)
+		os.Exit(exitError)
+	}
+	gh.Format = a.format
+	if a.format != formatCSV {
+		gh.File = reportName(a.csvName, a.format)
+	}
+
+	// Discover the orgs in the Github Enterprise account if one was provided
+	if len(gh.Enterprise) > 0 {
+		entTime := time.Now()
+		err := getEnterpriseOrgs(&gh)
+		if err != nil {
+			fmt.Printf("Error discovering Enterprise orgs was %+v\n", err)
+			os.Exit(exitError)
+		}
+		fmt.Printf("Get enterprise orgs done in %v\n", time.Since(entTime))
 	}
 
+	return &gh
 }
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Checks a policy rule can make against each repo
+const (
+	// Repos must have a license
+	checkLicense = "license"
+	// Repos must have no more than max admins added directly or as outside collaborators
+	checkMaxDirectAdmins = "max-direct-admins"
+	// Outside collaborators must not be repo admins
+	checkNoOutsideAdmin = "no-outside-admin"
+	// The default branch of repos must be protected
+	checkProtectedBranch = "default-branch-protected"
+)
+
+// Default messages for each check, used when a rule doesn't provide one
+var checkMessages = map[string]string{
+	checkLicense:         "Repo has no license",
+	checkMaxDirectAdmins: "Repo has too many direct admins",
+	checkNoOutsideAdmin:  "Outside collaborator is a repo admin",
+	checkProtectedBranch: "Default branch is not protected",
+}
+
+// Severities of a policy rule, from most to least severe
+var severities = []string{"critical", "high", "medium", "low"}
+
+// Severity used when a rule doesn't set one
+const defaultSeverity = "medium"
+
+// Struct to hold a policy file
+// e.g.
+//
+//	rules:
+//	  - id: public-license
+//	    check: license
+//	    visibility: public
+//	    severity: high
+//	    message: Public repos must have a license
+//	  - id: max-admins
+//	    check: max-direct-admins
+//	    max: 3
+type policy struct {
+	Rules []policyRule `yaml:"rules"`
+}
+
+// Struct to hold a single rule of a policy
+type policyRule struct {
+	ID       string `yaml:"id"`
+	Check    string `yaml:"check"`
+	Severity string `yaml:"severity"`
+	Message  string `yaml:"message"`
+	// Only apply the rule to repos with this visibility e.g. public, empty for all repos
+	Visibility string `yaml:"visibility"`
+	// Limit used by max-direct-admins
+	Max int `yaml:"max"`
+	// Archived repos are skipped unless this is true
+	IncludeArchived bool `yaml:"include_archived"`
+}
+
+// Struct to hold a repo which breaks a policy rule
+type policyViolation struct {
+	RuleID   string `json:"rule_id"`
+	Severity string `json:"severity"`
+	Org      string `json:"org"`
+	Repo     string `json:"repo"`
+	Message  string `json:"message"`
+	URL      string `json:"url"`
+	// User the violation is about, only set by no-outside-admin
+	Login string `json:"login,omitempty"`
+}
+
+// loadPolicy reads and validates a YAML policy file
+func loadPolicy(f string) (*policy, error) {
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	p := policy{}
+	err = yaml.Unmarshal(b, &p)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Problem reading policy file %s was: %v", f, err))
+	}
+	if len(p.Rules) == 0 {
+		return nil, errors.New(fmt.Sprintf("Policy file %s has no rules", f))
+	}
+
+	seen := make(map[string]bool)
+	for k := range p.Rules {
+		r := &p.Rules[k]
+		if len(r.ID) == 0 {
+			return nil, errors.New(fmt.Sprintf("Policy rule %d has no id", k+1))
+		}
+		if seen[r.ID] {
+			return nil, errors.New(fmt.Sprintf("Policy rule id '%s' is used more than once", r.ID))
+		}
+		seen[r.ID] = true
+		if _, exists := checkMessages[r.Check]; !exists {
+			return nil, errors.New(fmt.Sprintf("Policy rule '%s' has unknown check '%s'", r.ID, r.Check))
+		}
+		if r.Check == checkMaxDirectAdmins && r.Max < 1 {
+			return nil, errors.New(fmt.Sprintf("Policy rule '%s' needs a max of at least 1", r.ID))
+		}
+		r.Severity = strings.ToLower(r.Severity)
+		if len(r.Severity) == 0 {
+			r.Severity = defaultSeverity
+		}
+		if !containsString(severities, r.Severity) {
+			return nil, errors.New(fmt.Sprintf("Policy rule '%s' has unknown severity '%s'", r.ID, r.Severity))
+		}
+		if len(r.Message) == 0 {
+			r.Message = checkMessages[r.Check]
+		}
+	}
+
+	return &p, nil
+}
+
+// needsAccess returns true if a rule in the policy needs the source of each collaborator's access
+func (p *policy) needsAccess() bool {
+	for _, r := range p.Rules {
+		if r.Check == checkMaxDirectAdmins || r.Check == checkNoOutsideAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// needsProtection returns true if a rule in the policy needs default branch protection
+func (p *policy) needsProtection() bool {
+	for _, r := range p.Rules {
+		if r.Check == checkProtectedBranch {
+			return true
+		}
+	}
+
+	return false
+}
+
+// evaluatePolicy checks every repo of the orgs against each rule of the policy and returns
+// the violations found, most severe first
+func evaluatePolicy(p *policy, orgs []ghOrgData) []policyViolation {
+	var found []policyViolation
+	for _, o := range orgs {
+		for k, repo := range o.Repos {
+			for _, r := range p.Rules {
+				if !ruleApplies(r, repo.Archived, repo.Visibility) {
+					continue
+				}
+				for _, detail := range checkRepo(r, o, k) {
+					m := r.Message
+					if len(detail) > 0 {
+						m = m + " (" + detail + ")"
+					}
+					v := policyViolation{
+						RuleID:   r.ID,
+						Severity: r.Severity,
+						Org:      o.Org,
+						Repo:     repo.Name,
+						Message:  m,
+						URL:      repo.HTMLURL,
+					}
+					if r.Check == checkNoOutsideAdmin {
+						v.Login = detail
+					}
+					found = append(found, v)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return severityRank(found[i].Severity) < severityRank(found[j].Severity)
+	})
+
+	return found
+}
+
+// ruleApplies returns true if a rule should be checked against a repo with the archived
+// flag and visibility provided
+func ruleApplies(r policyRule, archived bool, visibility string) bool {
+	if archived && !r.IncludeArchived {
+		return false
+	}
+	if len(r.Visibility) > 0 && !strings.EqualFold(r.Visibility, visibility) {
+		return false
+	}
+
+	return true
+}
+
+// checkRepo takes a rule, an org's data and the index of one of its repos and returns a detail
+// for each way the repo breaks the rule, an empty detail when there is nothing to add to the
+// rule's message, or nil if the repo follows the rule
+func checkRepo(r policyRule, o ghOrgData, k int) []string {
+	repo := o.Repos[k].Name
+	switch r.Check {
+	case checkLicense:
+		if len(o.Repos[k].License.Key) == 0 {
+			return []string{""}
+		}
+	case checkMaxDirectAdmins:
+		var direct []string
+		for _, a := range o.Admins[repo] {
+			if accessSource(o, repo, a.Login) != accessInherited {
+				direct = append(direct, a.Login)
+			}
+		}
+		if len(direct) > r.Max {
+			return []string{strconv.Itoa(len(direct)) + " direct admins, max " + strconv.Itoa(r.Max) + ": " + strings.Join(direct, ", ")}
+		}
+	case checkNoOutsideAdmin:
+		var details []string
+		for _, a := range o.Admins[repo] {
+			if accessSource(o, repo, a.Login) == accessOutside {
+				details = append(details, a.Login)
+			}
+		}
+		return details
+	case checkProtectedBranch:
+		if len(o.Repos[k].DefaultBranch) > 0 && !o.Protected[repo] {
+			return []string{o.Repos[k].DefaultBranch}
+		}
+	}
+
+	return nil
+}
+
+// severityRank returns the position of a severity from most to least severe
+func severityRank(s string) int {
+	for k, v := range severities {
+		if v == s {
+			return k
+		}
+	}
+
+	return len(severities)
+}
+
+// violationsName takes the name of the report file and returns the name of the
+// violations report e.g. org-info.csv becomes org-info-violations.csv
+func violationsName(f string, ext string) string {
+	return strings.TrimSuffix(f, filepath.Ext(f)) + "-violations." + ext
+}
+
+// writeViolations takes a pointer to ghAPIClient and the policy violations found and writes
+// them out next to the report, as JSON for the JSON formats and as CSV otherwise
+func writeViolations(g *ghAPIClient, found []policyViolation) (string, error) {
+	if g.Format == formatJSON || g.Format == formatNDJSON {
+		f := violationsName(g.File, formatJSON)
+		if found == nil {
+			found = []policyViolation{}
+		}
+		b, err := json.MarshalIndent(found, "", "  ")
+		if err != nil {
+			return f, err
+		}
+		return f, ioutil.WriteFile(f, append(b, '\n'), 0644)
+	}
+
+	f := violationsName(g.File, formatCSV)
+	return f, writeViolationsCSV(f, found)
+}
+
+// writeViolationsCSV takes a file name and the policy violations found and writes
+// them out as a CSV
+func writeViolationsCSV(f string, found []policyViolation) error {
+	header := []string{
+		"Rule ID",  // e.g. public-license
+		"Severity", // critical, high, medium or low
+		"Org",      // e.g. my-github-org
+		"Repo",     // e.g. my-repo
+		"Message",  // e.g. Repo has no license
+		"Login",    // User the violation is about, only for no-outside-admin
+	}
+
+	// Add a line for each violation
+	var rows [][]string
+	for _, v := range found {
+		rows = append(rows, []string{v.RuleID, v.Severity, v.Org, v.Repo, v.Message, v.Login})
+	}
+
+	return writeCSVRows(f, header, rows)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testPolicy writes a policy file and loads it
+func testPolicy(t *testing.T, content string) (*policy, error) {
+	f := filepath.Join(t.TempDir(), "policy.yaml")
+	err := ioutil.WriteFile(f, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loadPolicy(f)
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []policyRule
+		wantErr bool
+	}{
+		{
+			name: "defaults filled in",
+			yaml: "rules:\n  - id: public-license\n    check: license\n    visibility: public\n    severity: HIGH\n" +
+				"  - id: max-admins\n    check: max-direct-admins\n    max: 3\n    message: Too many admins\n",
+			want: []policyRule{
+				{ID: "public-license", Check: checkLicense, Severity: "high", Message: checkMessages[checkLicense], Visibility: "public"},
+				{ID: "max-admins", Check: checkMaxDirectAdmins, Severity: defaultSeverity, Message: "Too many admins", Max: 3},
+			},
+		},
+		{name: "no rules", yaml: "rules: []\n", wantErr: true},
+		{name: "not YAML", yaml: "rules: [\n", wantErr: true},
+		{name: "no id", yaml: "rules:\n  - check: license\n", wantErr: true},
+		{name: "duplicate id", yaml: "rules:\n  - id: a\n    check: license\n  - id: a\n    check: license\n", wantErr: true},
+		{name: "unknown check", yaml: "rules:\n  - id: a\n    check: stars\n", wantErr: true},
+		{name: "max missing", yaml: "rules:\n  - id: a\n    check: max-direct-admins\n", wantErr: true},
+		{name: "unknown severity", yaml: "rules:\n  - id: a\n    check: license\n    severity: urgent\n", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := testPolicy(t, tc.yaml)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("loadPolicy returned error %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(p.Rules, tc.want) {
+				t.Errorf("loadPolicy rules =\n%+v\nwant\n%+v", p.Rules, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	// api has a license and a protected branch, web has neither and old is archived.
+	// jdoe and asmith are direct admins of api, owner an admin through a team and
+	// contractor an outside collaborator
+	d := ghOrgData{
+		Org: "acme",
+		Repos: ghRepoInfo{
+			{Name: "api", HTMLURL: "https://github.com/acme/api", Visibility: "public", DefaultBranch: "main"},
+			{Name: "web", HTMLURL: "https://github.com/acme/web", Visibility: "private", DefaultBranch: "main"},
+			{Name: "old", Visibility: "public", Archived: true, DefaultBranch: "main"},
+		},
+		Admins: map[string]ghCollaborators{
+			"api": {{Login: "jdoe"}, {Login: "asmith"}, {Login: "owner"}},
+			"web": {{Login: "contractor"}},
+		},
+		Direct:    map[string]map[string]bool{"api": {"jdoe": true, "asmith": true}},
+		Outside:   map[string]bool{"contractor": true},
+		Protected: map[string]bool{"api": true},
+	}
+	d.Repos[0].License.Key = "mit"
+
+	p := policy{Rules: []policyRule{
+		{ID: "license", Check: checkLicense, Severity: "low", Message: "No license"},
+		{ID: "max-admins", Check: checkMaxDirectAdmins, Severity: "medium", Message: "Too many admins", Max: 1},
+		{ID: "outside", Check: checkNoOutsideAdmin, Severity: "critical", Message: "Outside admin"},
+		{ID: "protected", Check: checkProtectedBranch, Severity: "high", Message: "Unprotected", Visibility: "PRIVATE"},
+		{ID: "archived-license", Check: checkLicense, Severity: "low", Message: "No license", IncludeArchived: true},
+	}}
+	got := evaluatePolicy(&p, []ghOrgData{d})
+	want := []policyViolation{
+		{RuleID: "outside", Severity: "critical", Org: "acme", Repo: "web", Message: "Outside admin (contractor)", URL: "https://github.com/acme/web", Login: "contractor"},
+		{RuleID: "protected", Severity: "high", Org: "acme", Repo: "web", Message: "Unprotected (main)", URL: "https://github.com/acme/web"},
+		{RuleID: "max-admins", Severity: "medium", Org: "acme", Repo: "api", Message: "Too many admins (2 direct admins, max 1: jdoe, asmith)", URL: "https://github.com/acme/api"},
+		{RuleID: "license", Severity: "low", Org: "acme", Repo: "web", Message: "No license", URL: "https://github.com/acme/web"},
+		{RuleID: "archived-license", Severity: "low", Org: "acme", Repo: "web", Message: "No license", URL: "https://github.com/acme/web"},
+		{RuleID: "archived-license", Severity: "low", Org: "acme", Repo: "old", Message: "No license"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("evaluatePolicy =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,605 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Changes a remediation plan can make
+const (
+	actionRemove        = "remove-collaborator"
+	actionDowngrade     = "downgrade"
+	actionCancelOrgInv  = "cancel-org-invitation"
+	actionCancelRepoInv = "cancel-repo-invitation"
+	actionArchive       = "archive-repo"
+)
+
+// Repo permissions a collaborator can be downgraded to
+var repoPermissions = []string{"pull", "triage", "push", "maintain"}
+
+// Header of the plan file, the apply command finds the columns by these labels
+var planHeader = []string{"Action", "Org", "Repo", "Login", "Permission", "Invitation ID", "Pushed At", "Reason"}
+
+// Struct to hold a single change in a remediation plan
+type remediation struct {
+	Action       string
+	Org          string
+	Repo         string
+	Login        string
+	Permission   string
+	InvitationID int
+	// Last push to a repo to archive when the plan was made, it's skipped if pushed to since
+	PushedAt time.Time
+	Reason   string
+}
+
+// remediateCommand handles the remediate command which plans changes from a revoke list,
+// policy violations, invitations and a previous report, or applies a plan, exiting when done
+func remediateCommand(args []string) {
+	fs := newFlagSet("remediate")
+	var revoke, violations, recommendations, invites, report, downgradeTo, plan, logFile string
+	var staleDays int
+	var configFile string
+	conn := ghConnection{}
+	configFlags(fs, &configFile)
+	connectionFlags(fs, &conn)
+	fs.StringVar(&revoke, "revoke", "", "Remove the collaborators in a revoke.csv written by review ingest")
+	fs.StringVar(&violations, "violations", "", "Downgrade the outside collaborators found by the no-outside-admin check in a\n"+
+		"-policy violations report (csv or json)")
+	fs.StringVar(&recommendations, "least-privilege", "", "Downgrade the admins in a CSV written with -least-privilege-days to the\n"+
+		"suggested role")
+	fs.StringVar(&invites, "invitations", "", "Cancel the stale invitations in a CSV written with -invitations")
+	fs.StringVar(&report, "report", "", "Archive the repos in a json report not pushed to in the number of days set\n"+
+		"with -archive-stale-days")
+	fs.IntVar(&staleDays, "archive-stale-days", 0, "Archive repos not pushed to in this many days")
+	fs.StringVar(&downgradeTo, "downgrade-to", "push", "Provide the permission outside admins are downgraded to: pull, triage, push\n"+
+		"or maintain")
+	fs.StringVar(&plan, "plan", "remediation-plan.csv", "Provide the plan file to write or apply")
+	fs.StringVar(&logFile, "log", "remediation-log.ndjson", "Provide the file every change is logged to")
+	action := parseAction(fs, args)
+	configArgs(fs)
+
+	switch action {
+	case "plan":
+		if !containsString(repoPermissions, downgradeTo) {
+			fmt.Printf("ERROR: Unsupported permission '%s', use %s\n", downgradeTo, strings.Join(repoPermissions, ", "))
+			os.Exit(exitError)
+		}
+		if len(report) > 0 && staleDays < 1 {
+			fmt.Println("ERROR: -report needs -archive-stale-days of at least 1")
+			os.Exit(exitError)
+		}
+		steps, err := planRemediation(revoke, violations, recommendations, invites, report, staleDays, downgradeTo)
+		if err != nil {
+			fmt.Printf("Error planning remediation was %+v\n", err)
+			os.Exit(exitError)
+		}
+		err = writePlan(plan, steps)
+		if err != nil {
+			fmt.Printf("Error writing plan was %+v\n", err)
+			os.Exit(exitError)
+		}
+		for _, v := range steps {
+			fmt.Printf("  %s\n", describeStep(v))
+		}
+		fmt.Printf("Planned %d changes, written to %s\n", len(steps), plan)
+		fmt.Printf("Nothing has been changed, review the plan then run: ghorg2csv remediate apply -plan %s\n", plan)
+	case "apply":
+		steps, err := loadPlan(plan)
+		if err != nil {
+			fmt.Printf("Error reading plan %s was %+v\n", plan, err)
+			os.Exit(exitError)
+		}
+		connectionArgs(conn)
+		skipped, failed, err := applyPlan(steps, conn, logFile)
+		if err != nil {
+			fmt.Printf("Error applying plan was %+v\n", err)
+			os.Exit(exitError)
+		}
+		fmt.Printf("Applied %d of %d changes, skipped %d no longer needed, logged to %s\n", len(steps)-skipped-failed, len(steps), skipped, logFile)
+		if failed > 0 {
+			os.Exit(exitError)
+		}
+	default:
+		fmt.Printf("Error: Unknown remediate command '%s'\n", action)
+		fs.Usage()
+		os.Exit(exitError)
+	}
+}
+
+// planRemediation builds the changes to make from each of the inputs provided, skipping
+// changes that are already planned. Collaborators can only be removed or downgraded repo by
+// repo when their access is direct, so access from a team or the org is left out
+func planRemediation(revoke string, violations string, recommendations string, invites string, report string, staleDays int, downgradeTo string) ([]remediation, error) {
+	var steps []remediation
+	seen := make(map[string]bool)
+	add := func(r remediation) {
+		key := strings.ToLower(r.Action + "/" + r.Org + "/" + r.Repo + "/" + r.Login + "/" + strconv.Itoa(r.InvitationID))
+		if !seen[key] {
+			seen[key] = true
+			steps = append(steps, r)
+		}
+	}
+
+	// Collaborators marked revoke in an access review
+	if len(revoke) > 0 {
+		rows, col, err := readCSVColumns(revoke)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Problem reading %s was: %v", revoke, err))
+		}
+		for _, row := range rows[1:] {
+			if csvValue(row, col, "source") == accessInherited {
+				fmt.Printf("Skipping removal of %s from %s/%s, their access comes from a team or the org\n",
+					csvValue(row, col, "login"), csvValue(row, col, "org"), csvValue(row, col, "repo"))
+				continue
+			}
+			add(remediation{
+				Action: actionRemove,
+				Org:    csvValue(row, col, "org"),
+				Repo:   csvValue(row, col, "repo"),
+				Login:  csvValue(row, col, "login"),
+				Reason: "Revoked in access review by " + csvValue(row, col, "reviewer"),
+			})
+		}
+	}
+
+	// Outside collaborators with admin
+	if len(violations) > 0 {
+		found, err := loadViolations(violations)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Problem reading %s was: %v", violations, err))
+		}
+		for _, v := range found {
+			// Nothing to downgrade for users already being removed
+			if len(v.Login) == 0 || seen[strings.ToLower(actionRemove+"/"+v.Org+"/"+v.Repo+"/"+v.Login+"/0")] {
+				continue
+			}
+			add(remediation{
+				Action:     actionDowngrade,
+				Org:        v.Org,
+				Repo:       v.Repo,
+				Login:      v.Login,
+				Permission: downgradeTo,
+				Reason:     "Policy " + v.RuleID + ": " + v.Message,
+			})
+		}
+	}
+
+	// Admins who don't write to the repo
+	if len(recommendations) > 0 {
+		rows, col, err := readCSVColumns(recommendations)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Problem reading %s was: %v", recommendations, err))
+		}
+		for _, row := range rows[1:] {
+			org, repo, l := csvValue(row, col, "org"), csvValue(row, col, "repo"), csvValue(row, col, "login")
+			if seen[strings.ToLower(actionRemove+"/"+org+"/"+repo+"/"+l+"/0")] {
+				continue
+			}
+			if csvValue(row, col, "source") == accessInherited {
+				fmt.Printf("Skipping downgrade of %s on %s/%s, their access comes from a team or the org\n", l, org, repo)
+				continue
+			}
+			add(remediation{
+				Action:     actionDowngrade,
+				Org:        org,
+				Repo:       repo,
+				Login:      l,
+				Permission: apiPermission(csvValue(row, col, "suggested role")),
+				Reason:     csvValue(row, col, "evidence"),
+			})
+		}
+	}
+
+	// Stale invitations
+	if len(invites) > 0 {
+		rows, col, err := readCSVColumns(invites)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Problem reading %s was: %v", invites, err))
+		}
+		if _, exists := col["id"]; !exists {
+			return nil, errors.New(fmt.Sprintf("%s has no 'ID' column, rerun the report with -invitations", invites))
+		}
+		for _, row := range rows[1:] {
+			if stale, _ := strconv.ParseBool(csvValue(row, col, "stale")); !stale {
+				continue
+			}
+			id, err := strconv.Atoi(csvValue(row, col, "id"))
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("%s has an invalid invitation ID '%s'", invites, csvValue(row, col, "id")))
+			}
+			r := remediation{
+				Action:       actionCancelRepoInv,
+				Org:          csvValue(row, col, "org"),
+				Repo:         csvValue(row, col, "repo"),
+				Login:        csvValue(row, col, "invitee"),
+				InvitationID: id,
+				Reason:       "Stale invitation sent " + csvValue(row, col, "age (days)") + " days ago",
+			}
+			if csvValue(row, col, "level") == inviteOrg {
+				r.Action = actionCancelOrgInv
+			}
+			add(r)
+		}
+	}
+
+	// Repos nobody has pushed to in a long time
+	if len(report) > 0 {
+		b, err := ioutil.ReadFile(report)
+		if err != nil {
+			return nil, err
+		}
+		rpt := jsonReport{}
+		err = json.Unmarshal(b, &rpt)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Problem reading %s was: %v", report, err))
+		}
+		cutoff := rpt.GeneratedAt.AddDate(0, 0, -staleDays)
+		for _, o := range rpt.Orgs {
+			for _, r := range o.Repos {
+				if r.Archived || r.PushedAt.After(cutoff) {
+					continue
+				}
+				add(remediation{
+					Action:   actionArchive,
+					Org:      o.Org,
+					Repo:     r.Name,
+					PushedAt: r.PushedAt,
+					Reason:   "Last pushed to " + r.PushedAt.Format("2006-01-02"),
+				})
+			}
+		}
+	}
+
+	return steps, nil
+}
+
+// apiPermission maps a repo role as shown on Github to the permission name used by the API
+func apiPermission(r string) string {
+	switch strings.ToLower(r) {
+	case "read":
+		return "pull"
+	case "write":
+		return "push"
+	}
+
+	return strings.ToLower(r)
+}
+
+// loadViolations reads a policy violations report written as either CSV or JSON
+func loadViolations(f string) ([]policyViolation, error) {
+	if strings.ToLower(filepath.Ext(f)) == ".json" {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var found []policyViolation
+		err = json.Unmarshal(b, &found)
+		return found, err
+	}
+
+	rows, col, err := readCSVColumns(f)
+	if err != nil {
+		return nil, err
+	}
+	var found []policyViolation
+	for _, row := range rows[1:] {
+		found = append(found, policyViolation{
+			RuleID:   csvValue(row, col, "rule id"),
+			Severity: csvValue(row, col, "severity"),
+			Org:      csvValue(row, col, "org"),
+			Repo:     csvValue(row, col, "repo"),
+			Message:  csvValue(row, col, "message"),
+			Login:    csvValue(row, col, "login"),
+		})
+	}
+
+	return found, nil
+}
+
+// describeStep returns a line describing a change in a plan
+func describeStep(r remediation) string {
+	switch r.Action {
+	case actionRemove:
+		return fmt.Sprintf("Remove %s from %s/%s - %s", r.Login, r.Org, r.Repo, r.Reason)
+	case actionDowngrade:
+		return fmt.Sprintf("Downgrade %s to %s on %s/%s - %s", r.Login, r.Permission, r.Org, r.Repo, r.Reason)
+	case actionCancelOrgInv:
+		return fmt.Sprintf("Cancel invitation %d of %s to %s - %s", r.InvitationID, r.Login, r.Org, r.Reason)
+	case actionCancelRepoInv:
+		return fmt.Sprintf("Cancel invitation %d of %s to %s/%s - %s", r.InvitationID, r.Login, r.Org, r.Repo, r.Reason)
+	case actionArchive:
+		return fmt.Sprintf("Archive %s/%s - %s", r.Org, r.Repo, r.Reason)
+	}
+
+	return "Unknown action " + r.Action
+}
+
+// writePlan writes the changes of a plan to a CSV
+func writePlan(f string, steps []remediation) error {
+	var rows [][]string
+	for _, v := range steps {
+		id := ""
+		if v.InvitationID > 0 {
+			id = strconv.Itoa(v.InvitationID)
+		}
+		pushed := ""
+		if !v.PushedAt.IsZero() {
+			pushed = v.PushedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{v.Action, v.Org, v.Repo, v.Login, v.Permission, id, pushed, v.Reason})
+	}
+
+	return writeCSVRows(f, planHeader, rows)
+}
+
+// loadPlan reads the changes of a plan CSV, checking each has what its action needs
+func loadPlan(f string) ([]remediation, error) {
+	rows, col, err := readCSVColumns(f)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range planHeader {
+		if _, exists := col[strings.ToLower(c)]; !exists {
+			return nil, errors.New(fmt.Sprintf("Plan has no '%s' column, create one with remediate plan", c))
+		}
+	}
+
+	var steps []remediation
+	for k, row := range rows[1:] {
+		r := remediation{
+			Action:     csvValue(row, col, "action"),
+			Org:        csvValue(row, col, "org"),
+			Repo:       csvValue(row, col, "repo"),
+			Login:      csvValue(row, col, "login"),
+			Permission: csvValue(row, col, "permission"),
+			Reason:     csvValue(row, col, "reason"),
+		}
+		if id := csvValue(row, col, "invitation id"); len(id) > 0 {
+			r.InvitationID, err = strconv.Atoi(id)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Plan line %d has an invalid invitation ID '%s'", k+2, id))
+			}
+		}
+		if pushed := csvValue(row, col, "pushed at"); len(pushed) > 0 {
+			r.PushedAt, err = time.Parse(time.RFC3339, pushed)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Plan line %d has an invalid pushed at time '%s'", k+2, pushed))
+			}
+		}
+
+		// Check the change has the values it needs
+		ok := len(r.Org) > 0
+		switch r.Action {
+		case actionRemove:
+			ok = ok && len(r.Repo) > 0 && len(r.Login) > 0
+		case actionDowngrade:
+			ok = ok && len(r.Repo) > 0 && len(r.Login) > 0 && containsString(repoPermissions, r.Permission)
+		case actionCancelOrgInv:
+			ok = ok && r.InvitationID > 0
+		case actionCancelRepoInv:
+			ok = ok && len(r.Repo) > 0 && r.InvitationID > 0
+		case actionArchive:
+			ok = ok && len(r.Repo) > 0 && !r.PushedAt.IsZero()
+		default:
+			return nil, errors.New(fmt.Sprintf("Plan line %d has unknown action '%s'", k+2, r.Action))
+		}
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Plan line %d is missing values needed to %s", k+2, r.Action))
+		}
+		steps = append(steps, r)
+	}
+
+	return steps, nil
+}
+
+// applyPlan makes each change of a plan through the Github API connected to with conn, logging
+// every change to a file. Each change is first checked against the current state on Github
+// as the plan may be out of date. Changes no longer needed are skipped and those that fail are
+// reported, returning the number of each
+func applyPlan(steps []remediation, conn ghConnection, logFile string) (int, int, error) {
+	g := ghAPIClient{}
+	err := setupClient(&g, conn, "", "", "", "")
+	if err != nil {
+		return 0, 0, err
+	}
+	fi, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer fi.Close()
+	g.MutationLog = fi
+
+	applyTime := time.Now()
+	check := planCheck{g: &g, direct: make(map[string]map[string]string), invites: make(map[string]map[int]bool)}
+	skipped, failed := 0, 0
+	for _, v := range steps {
+		reason, err := check.skipReason(v)
+		if err != nil {
+			failed++
+			fmt.Printf("  FAILED %s: Problem checking the change is still needed was: %v\n", describeStep(v), err)
+			continue
+		}
+		if len(reason) > 0 {
+			skipped++
+			fmt.Printf("  Skipped %s: %s\n", describeStep(v), reason)
+			continue
+		}
+
+		switch v.Action {
+		case actionRemove:
+			err = removeCollaborator(&g, v.Org, v.Repo, v.Login)
+		case actionDowngrade:
+			err = setCollaboratorPermission(&g, v.Org, v.Repo, v.Login, v.Permission)
+			if err == nil {
+				err = check.downgraded(v)
+			}
+		case actionCancelOrgInv:
+			err = cancelOrgInvitation(&g, v.Org, v.InvitationID)
+		case actionCancelRepoInv:
+			err = cancelRepoInvitation(&g, v.Org, v.Repo, v.InvitationID)
+		case actionArchive:
+			err = archiveRepo(&g, v.Org, v.Repo)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("  FAILED %s: %v\n", describeStep(v), err)
+			continue
+		}
+		fmt.Printf("  Done %s\n", describeStep(v))
+	}
+	fmt.Printf("Apply plan done in %v\n", time.Since(applyTime))
+
+	return skipped, failed, nil
+}
+
+// Struct to hold what's been looked up to check the changes of a plan are still needed, cached
+// by lower case org/repo (or org for org invitations) as a plan often has many changes for each
+type planCheck struct {
+	g *ghAPIClient
+	// Role of each direct collaborator keyed by lower case login
+	direct map[string]map[string]string
+	// IDs of the pending invitations
+	invites map[string]map[int]bool
+}
+
+// skipReason checks a change against the current state on Github and returns why it's no
+// longer needed or would do the wrong thing, or an empty string if it should be made
+func (c *planCheck) skipReason(v remediation) (string, error) {
+	switch v.Action {
+	case actionRemove, actionDowngrade:
+		// Removing or setting the permission of anyone but a direct collaborator does nothing
+		// for access from a team or the org, and setting it invites users who've left the repo
+		roles, err := c.directRoles(v.Org, v.Repo)
+		if err != nil {
+			return "", err
+		}
+		role, isDirect := roles[strings.ToLower(v.Login)]
+		if !isDirect {
+			return v.Login + " isn't a direct collaborator, any access they have comes from a team or the org", nil
+		}
+		if v.Action == actionDowngrade && !higherRole(role, v.Permission) {
+			return v.Login + " already has " + role, nil
+		}
+	case actionCancelOrgInv, actionCancelRepoInv:
+		pending, err := c.pendingInvites(v)
+		if err != nil {
+			return "", err
+		}
+		if !pending[v.InvitationID] {
+			return "The invitation is no longer pending", nil
+		}
+	case actionArchive:
+		r, err := getRepoState(c.g, v.Org, v.Repo)
+		if err != nil {
+			return "", err
+		}
+		if r.Archived {
+			return "The repo is already archived", nil
+		}
+		if r.PushedAt.After(v.PushedAt) {
+			return "The repo was pushed to on " + r.PushedAt.Format("2006-01-02") + " after the plan was written", nil
+		}
+	}
+
+	return "", nil
+}
+
+// downgraded checks a user's role on a repo after their direct permission was set, returning an
+// error if they still have a higher role e.g. admin through a team
+func (c *planCheck) downgraded(v remediation) error {
+	role, err := getCollaboratorPermission(c.g, v.Org, v.Repo, v.Login)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Problem checking the new permission was: %v", err))
+	}
+	if higherRole(role, v.Permission) {
+		return errors.New(fmt.Sprintf("Direct permission set to %s but %s still has %s through a team or the org", v.Permission, v.Login, role))
+	}
+
+	return nil
+}
+
+// directRoles returns the role of each direct collaborator of a repo keyed by lower case login
+func (c *planCheck) directRoles(org string, repo string) (map[string]string, error) {
+	key := strings.ToLower(org + "/" + repo)
+	if roles, exists := c.direct[key]; exists {
+		return roles, nil
+	}
+
+	setOrg(c.g, org)
+	direct := ghCollaborators{}
+	err := getDirectCollabs(c.g, repo, &direct)
+	if err != nil {
+		return nil, err
+	}
+	roles := make(map[string]string)
+	for _, v := range direct {
+		roles[strings.ToLower(v.Login)] = v.RoleName
+	}
+	c.direct[key] = roles
+
+	return roles, nil
+}
+
+// pendingInvites returns the IDs of the pending invitations of the org or repo of a change
+func (c *planCheck) pendingInvites(v remediation) (map[int]bool, error) {
+	key := strings.ToLower(v.Org)
+	if v.Action == actionCancelRepoInv {
+		key += "/" + strings.ToLower(v.Repo)
+	}
+	if ids, exists := c.invites[key]; exists {
+		return ids, nil
+	}
+
+	setOrg(c.g, v.Org)
+	ids := make(map[int]bool)
+	if v.Action == actionCancelRepoInv {
+		inv := ghRepoInvitations{}
+		err := getRepoInvitations(c.g, v.Repo, &inv)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range inv {
+			ids[i.ID] = true
+		}
+	} else {
+		inv := ghOrgInvitations{}
+		err := getOrgInvitations(c.g, &inv)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range inv {
+			ids[i.ID] = true
+		}
+	}
+	c.invites[key] = ids
+
+	return ids, nil
+}
+
+// higherRole returns true if a repo role is above the permission p e.g. admin is above push.
+// Custom roles can't be ranked so are treated as higher
+func higherRole(role string, p string) bool {
+	ranks := append(append([]string{}, repoPermissions...), "admin")
+	r, t := -1, -1
+	for k, v := range ranks {
+		if v == apiPermission(role) {
+			r = k
+		}
+		if v == apiPermission(p) {
+			t = k
+		}
+	}
+	if r < 0 {
+		return true
+	}
+
+	return r > t
+}
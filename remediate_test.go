@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeTestFile writes content to a file named n in a temporary directory and returns its path
+func writeTestFile(t *testing.T, n string, content string) string {
+	f := filepath.Join(t.TempDir(), n)
+	err := ioutil.WriteFile(f, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func TestHigherRole(t *testing.T) {
+	tests := []struct {
+		role string
+		p    string
+		want bool
+	}{
+		{"admin", "push", true},
+		{"admin", "maintain", true},
+		{"write", "push", false},
+		{"write", "pull", true},
+		{"read", "pull", false},
+		{"triage", "push", false},
+		{"maintain", "triage", true},
+		{"security-manager", "pull", true},
+	}
+	for _, tc := range tests {
+		if got := higherRole(tc.role, tc.p); got != tc.want {
+			t.Errorf("higherRole(%q, %q) = %v, want %v", tc.role, tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestPlanRemediation(t *testing.T) {
+	revoke := writeTestFile(t, "revoke.csv", "Org,Repo,Login,Permission,Source,Reviewer,Comment\n"+
+		"acme,api,jdoe,write,direct,boss,\n"+
+		"acme,api,team-member,write,team-or-org,boss,\n")
+	violations := writeTestFile(t, "violations.csv", "Rule ID,Severity,Org,Repo,Message,Login\n"+
+		"no-outside-admin,high,acme,api,Outside collaborator jdoe is an admin,jdoe\n"+
+		"no-outside-admin,high,acme,web,Outside collaborator jdoe is an admin,jdoe\n"+
+		"public-license,low,acme,web,Repo has no license,\n")
+	recommendations := writeTestFile(t, "least-privilege.csv", "Org,Repo,Login,Current Role,Source,Suggested Role,Last Activity,Evidence\n"+
+		"acme,web,asmith,admin,direct,triage,2022-06-01,Reviews only\n"+
+		"acme,web,jdoe,admin,outside-collaborator,read,,No pushes\n"+
+		"acme,web,owner,admin,team-or-org,read,,No pushes\n")
+	invites := writeTestFile(t, "invitations.csv", "Org,Level,Repo,Invitee,Inviter,Role,Invited At,Age (Days),Stale,ID\n"+
+		"acme,org,,carol,boss,direct_member,2022-01-01T00:00:00Z,30,true,11\n"+
+		"acme,repo,api,erin,boss,write,2022-01-01T00:00:00Z,30,true,12\n"+
+		"acme,repo,api,fresh,boss,write,2022-01-01T00:00:00Z,1,false,13\n")
+	report := writeTestFile(t, "report.json", `{"generated_at":"2022-06-30T00:00:00Z","orgs":[{"org":"acme","repos":[`+
+		`{"name":"old","full_name":"acme/old","pushed_at":"2021-01-01T00:00:00Z"},`+
+		`{"name":"archived","full_name":"acme/archived","archived":true,"pushed_at":"2020-01-01T00:00:00Z"},`+
+		`{"name":"active","full_name":"acme/active","pushed_at":"2022-06-01T00:00:00Z"}]}]}`)
+
+	got, err := planRemediation(revoke, violations, recommendations, invites, report, 180, "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []remediation{
+		{Action: actionRemove, Org: "acme", Repo: "api", Login: "jdoe", Reason: "Revoked in access review by boss"},
+		{Action: actionDowngrade, Org: "acme", Repo: "web", Login: "jdoe", Permission: "push", Reason: "Policy no-outside-admin: Outside collaborator jdoe is an admin"},
+		{Action: actionDowngrade, Org: "acme", Repo: "web", Login: "asmith", Permission: "triage", Reason: "Reviews only"},
+		{Action: actionCancelOrgInv, Org: "acme", Login: "carol", InvitationID: 11, Reason: "Stale invitation sent 30 days ago"},
+		{Action: actionCancelRepoInv, Org: "acme", Repo: "api", Login: "erin", InvitationID: 12, Reason: "Stale invitation sent 30 days ago"},
+		{Action: actionArchive, Org: "acme", Repo: "old", PushedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Reason: "Last pushed to 2021-01-01"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planRemediation =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestLoadPlan(t *testing.T) {
+	header := strings.Join(planHeader, ",") + "\n"
+	tests := []struct {
+		name    string
+		plan    string
+		want    []remediation
+		wantErr bool
+	}{
+		{
+			name: "every action",
+			plan: header +
+				"remove-collaborator,acme,api,jdoe,,,,Revoked\n" +
+				"downgrade,acme,api,asmith,triage,,,Reviews only\n" +
+				"cancel-org-invitation,acme,,carol,,11,,Stale\n" +
+				"cancel-repo-invitation,acme,api,erin,,12,,Stale\n" +
+				"archive-repo,acme,old,,,,2021-01-01T00:00:00Z,Last pushed\n",
+			want: []remediation{
+				{Action: actionRemove, Org: "acme", Repo: "api", Login: "jdoe", Reason: "Revoked"},
+				{Action: actionDowngrade, Org: "acme", Repo: "api", Login: "asmith", Permission: "triage", Reason: "Reviews only"},
+				{Action: actionCancelOrgInv, Org: "acme", Login: "carol", InvitationID: 11, Reason: "Stale"},
+				{Action: actionCancelRepoInv, Org: "acme", Repo: "api", Login: "erin", InvitationID: 12, Reason: "Stale"},
+				{Action: actionArchive, Org: "acme", Repo: "old", PushedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Reason: "Last pushed"},
+			},
+		},
+		{name: "header only", plan: header},
+		{name: "missing column", plan: "Action,Org,Repo\nremove-collaborator,acme,api\n", wantErr: true},
+		{name: "unknown action", plan: header + "delete-repo,acme,api,,,,,\n", wantErr: true},
+		{name: "downgrade to admin", plan: header + "downgrade,acme,api,jdoe,admin,,,\n", wantErr: true},
+		{name: "remove without login", plan: header + "remove-collaborator,acme,api,,,,,\n", wantErr: true},
+		{name: "invalid invitation ID", plan: header + "cancel-org-invitation,acme,,carol,,x,,\n", wantErr: true},
+		{name: "archive without pushed at", plan: header + "archive-repo,acme,old,,,,,\n", wantErr: true},
+		{name: "invalid pushed at", plan: header + "archive-repo,acme,old,,,,2021-01-01,\n", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := loadPlan(writeTestFile(t, "plan.csv", tc.plan))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("loadPlan returned error %v, want error %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("loadPlan =\n%+v\nwant\n%+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// remediationServer returns a test server for the repos of acme which records the mutations
+// sent to it. direct is a direct admin of api, teamadmin an admin of api through a team and
+// mixed a direct writer who is also an admin through a team
+func remediationServer(t *testing.T, mutations *[]string, mu *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			if r.Header.Get("Accept") != "application/vnd.github+json" {
+				t.Errorf("%s %s sent Accept %q", r.Method, r.URL.Path, r.Header.Get("Accept"))
+			}
+			if r.ContentLength > 0 && r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("%s %s sent Content-Type %q", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+			}
+			mu.Lock()
+			*mutations = append(*mutations, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/acme/api/collaborators":
+			if r.URL.Query().Get("affiliation") != "direct" {
+				t.Errorf("listed collaborators with affiliation %q", r.URL.Query().Get("affiliation"))
+			}
+			fmt.Fprint(w, `[{"login":"direct","role_name":"admin"},{"login":"mixed","role_name":"write"},{"login":"reader","role_name":"read"}]`)
+		case "/repos/acme/api/collaborators/direct/permission":
+			fmt.Fprint(w, `{"permission":"write","role_name":"write"}`)
+		case "/repos/acme/api/collaborators/mixed/permission":
+			fmt.Fprint(w, `{"permission":"admin","role_name":"admin"}`)
+		case "/orgs/acme/invitations":
+			fmt.Fprint(w, `[{"id":11}]`)
+		case "/repos/acme/api/invitations":
+			fmt.Fprint(w, `[]`)
+		case "/repos/acme/old":
+			fmt.Fprint(w, `{"archived":false,"pushed_at":"2021-01-01T00:00:00Z"}`)
+		case "/repos/acme/done":
+			fmt.Fprint(w, `{"archived":true,"pushed_at":"2021-01-01T00:00:00Z"}`)
+		case "/repos/acme/revived":
+			fmt.Fprintf(w, `{"archived":false,"pushed_at":"%s"}`, time.Now().UTC().Format(time.RFC3339))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestApplyPlan(t *testing.T) {
+	var mutations []string
+	var mu sync.Mutex
+	srv := remediationServer(t, &mutations, &mu)
+	defer srv.Close()
+	t.Setenv("GHTOKEN", "test-token")
+
+	// Each repo to archive was last pushed to when the plan was made, revived has been pushed to since
+	pushed := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []remediation{
+		{Action: actionDowngrade, Org: "acme", Repo: "api", Login: "direct", Permission: "push"},
+		{Action: actionDowngrade, Org: "acme", Repo: "api", Login: "teamadmin", Permission: "push"},
+		{Action: actionDowngrade, Org: "acme", Repo: "api", Login: "gone", Permission: "pull"},
+		{Action: actionDowngrade, Org: "acme", Repo: "api", Login: "reader", Permission: "triage"},
+		{Action: actionDowngrade, Org: "acme", Repo: "api", Login: "mixed", Permission: "pull"},
+		{Action: actionRemove, Org: "acme", Repo: "api", Login: "Reader"},
+		{Action: actionRemove, Org: "acme", Repo: "api", Login: "teamadmin"},
+		{Action: actionCancelOrgInv, Org: "acme", InvitationID: 11},
+		{Action: actionCancelOrgInv, Org: "acme", InvitationID: 99},
+		{Action: actionCancelRepoInv, Org: "acme", Repo: "api", InvitationID: 12},
+		{Action: actionArchive, Org: "acme", Repo: "old", PushedAt: pushed},
+		{Action: actionArchive, Org: "acme", Repo: "done", PushedAt: pushed},
+		{Action: actionArchive, Org: "acme", Repo: "revived", PushedAt: pushed},
+		{Action: actionArchive, Org: "acme", Repo: "missing", PushedAt: pushed},
+	}
+	logFile := filepath.Join(t.TempDir(), "log.ndjson")
+	skipped, failed, err := applyPlan(steps, ghConnection{APIURL: srv.URL}, logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// gone, teamadmin twice, reader's downgrade, invitations 99 and 12, done and revived are
+	// skipped while mixed is still an admin after the downgrade and missing doesn't exist
+	if skipped != 8 || failed != 2 {
+		t.Errorf("applyPlan skipped %d and failed %d, want 8 and 2", skipped, failed)
+	}
+	want := []string{
+		"PUT /repos/acme/api/collaborators/direct",
+		"PUT /repos/acme/api/collaborators/mixed",
+		"DELETE /repos/acme/api/collaborators/Reader",
+		"DELETE /orgs/acme/invitations/11",
+		"PATCH /repos/acme/old",
+	}
+	if !reflect.DeepEqual(mutations, want) {
+		t.Errorf("applyPlan sent\n%q\nwant\n%q", mutations, want)
+	}
+
+	// Every mutation is logged
+	b, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("logged %d mutations, want %d", len(lines), len(want))
+	}
+	m := ghMutation{}
+	err = json.Unmarshal([]byte(lines[0]), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Method != http.MethodPut || string(m.Body) != `{"permission":"push"}` || m.Status != http.StatusNoContent {
+		t.Errorf("logged %+v for the first downgrade", m)
+	}
+}
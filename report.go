@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Supported output formats for the report
+const (
+	formatCSV  = "csv"
+	formatJSON = "json"
+	// Newline delimited JSON, written one repo per line while collecting
+	formatNDJSON = "ndjson"
+	// Excel workbook with a sheet per dataset
+	formatXLSX = "xlsx"
+	// Self-contained HTML report
+	formatHTML = "html"
+	// Normalized SQLite database
+	formatSQLite = "sqlite"
+)
+
+// Supported layouts for the main CSV
+const (
+	// One row per repo with the admins in a single column
+	layoutWide = "wide"
+	// One row per (repo, user, permission, source)
+	layoutLong = "long"
+)
+
+// validFormat returns true if f is a supported output format
+func validFormat(f string) bool {
+	switch f {
+	case formatCSV, formatJSON, formatNDJSON, formatXLSX, formatHTML, formatSQLite:
+		return true
+	}
+
+	return false
+}
+
+// reportName takes the name provided with the csv argument and an output format and
+// returns the file name to use for that format e.g. org-info.csv becomes org-info.json
+func reportName(c string, f string) string {
+	return strings.TrimSuffix(c, ".csv") + "." + f
+}
+
+// writeReport takes a pointer to ghAPIClient and the data collected for one or more orgs
+// and writes the report out in the format set in the Format field of ghAPIClient along
+// with any CSVs of findings
+func writeReport(g *ghAPIClient, orgs []ghOrgData) error {
+	err := writeMainReport(g, orgs)
+	if err != nil {
+		return err
+	}
+
+	return writeFindingsCSVs(g, orgs)
+}
+
+// writeMainReport takes a pointer to ghAPIClient and the data collected for one or more orgs
+// and writes the report out in the format set in the Format field of ghAPIClient
+func writeMainReport(g *ghAPIClient, orgs []ghOrgData) error {
+	switch g.Format {
+	case formatJSON:
+		jsonTime := time.Now()
+		err := writeJSON(g.File, orgs, g.Orphans)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing JSON file was: %v", err))
+		}
+		fmt.Printf("Write JSON done in %v\n", time.Since(jsonTime))
+		return nil
+	case formatXLSX:
+		xlsxTime := time.Now()
+		err := writeXLSX(g.File, orgs)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing XLSX file was: %v", err))
+		}
+		fmt.Printf("Write XLSX done in %v\n", time.Since(xlsxTime))
+		return nil
+	case formatHTML:
+		htmlTime := time.Now()
+		err := writeHTML(g.File, orgs)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing HTML file was: %v", err))
+		}
+		fmt.Printf("Write HTML done in %v\n", time.Since(htmlTime))
+		return nil
+	case formatSQLite:
+		dbTime := time.Now()
+		err := writeSQLite(g.File, orgs)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Problem writing SQLite database was: %v", err))
+		}
+		fmt.Printf("Write SQLite done in %v\n", time.Since(dbTime))
+		return nil
+	case formatNDJSON:
+		// Repos were written as they were collected
+		fmt.Printf("NDJSON streamed to %s\n", g.File)
+		return nil
+	}
+
+	return writeCSVReports(g, orgs)
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Ways of assigning grants to reviewers
+const (
+	reviewByManager = "manager"
+	reviewByOwner   = "owner"
+)
+
+// Decisions a reviewer can make on a grant, an empty decision is still pending
+const (
+	decisionKeep    = "keep"
+	decisionRevoke  = "revoke"
+	decisionPending = "pending"
+)
+
+// Reviewer for grants with no manager or repo owner to review them
+const unassignedReviewer = "unassigned"
+
+// Header of the review files, the ingest command finds the columns by these labels
+var reviewHeader = []string{"Review ID", "Reviewer", "Full Name", "Login", "Permission", "Source", "Decision", "Comment"}
+
+// Characters not safe to use in a review file name
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Struct to hold a single user's access to a repo under review
+type reviewGrant struct {
+	ID         string
+	Reviewer   string
+	FullName   string
+	Login      string
+	Permission string
+	Source     string
+	Manager    string
+	Decision   string
+	Comment    string
+	File       string
+}
+
+// reviewCommand handles the review command which splits a report into review files or
+// ingests the completed review files, exiting when done
+func reviewCommand(args []string) {
+	fs := newFlagSet("review")
+	var by, out string
+	fs.StringVar(&by, "by", reviewByManager, "Provide who reviews each grant, either manager or owner. manager is the\n"+
+		"manager of the user from the roster, owner is the first admin of the repo by\n"+
+		"login. Grants without one are written to review-unassigned.csv")
+	fs.StringVar(&out, "out", "access-review", "Provide the directory to write to")
+	action := parseAction(fs, args)
+
+	switch action {
+	case "split":
+		if fs.NArg() != 1 {
+			fmt.Println("Error: review split needs exactly one report")
+			fs.Usage()
+			os.Exit(exitError)
+		}
+		if by != reviewByManager && by != reviewByOwner {
+			fmt.Printf("ERROR: Unsupported reviewer '%s', use manager or owner\n", by)
+			os.Exit(exitError)
+		}
+		grants, err := loadGrants(fs.Arg(0))
+		if err != nil {
+			fmt.Printf("Error reading %s was %+v\n", fs.Arg(0), err)
+			os.Exit(exitError)
+		}
+		assignReviewers(grants, by)
+		files, err := writeReviewFiles(out, grants)
+		if err != nil {
+			fmt.Printf("Error writing review files was %+v\n", err)
+			os.Exit(exitError)
+		}
+		fmt.Printf("Wrote %d grants to %d review files in %s\n", len(grants), files, out)
+	case "ingest":
+		if fs.NArg() == 0 {
+			fmt.Println("Error: review ingest needs the completed review files or their directory")
+			fs.Usage()
+			os.Exit(exitError)
+		}
+		grants, err := loadReviews(fs.Args())
+		if err != nil {
+			fmt.Printf("Error reading review files was %+v\n", err)
+			os.Exit(exitError)
+		}
+		err = writeAttestation(out, grants)
+		if err != nil {
+			fmt.Printf("Error writing attestation was %+v\n", err)
+			os.Exit(exitError)
+		}
+		kept, revoked, pending := countDecisions(grants)
+		fmt.Printf("Reviewed %d grants: %d keep, %d revoke, %d pending\n", len(grants), kept, revoked, pending)
+		fmt.Printf("Wrote %s and %s\n", filepath.Join(out, "attestation.csv"), filepath.Join(out, "revoke.csv"))
+	default:
+		fmt.Printf("Error: Unknown review command '%s'\n", action)
+		fs.Usage()
+		os.Exit(exitError)
+	}
+}
+
+// loadGrants reads the grants from a previous report, picking the parser from the file extension
+func loadGrants(f string) ([]reviewGrant, error) {
+	switch strings.ToLower(filepath.Ext(f)) {
+	case ".json":
+		return loadJSONGrants(f)
+	case ".csv":
+		return loadCSVGrants(f)
+	}
+
+	return nil, errors.New("Unsupported report type, use a .csv or .json file")
+}
+
+// loadJSONGrants reads the admin grants from a report written with -format json
+func loadJSONGrants(f string) ([]reviewGrant, error) {
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	rpt := jsonReport{}
+	err = json.Unmarshal(b, &rpt)
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []reviewGrant
+	for _, o := range rpt.Orgs {
+		for _, r := range o.Repos {
+			for _, a := range r.Admins {
+				grants = append(grants, reviewGrant{
+					FullName:   r.FullName,
+					Login:      a.Login,
+					Permission: a.Permission,
+					Manager:    a.Manager,
+				})
+			}
+		}
+	}
+
+	return grants, nil
+}
+
+// loadCSVGrants reads the grants from a report written with -layout long. The columns are
+// found by their default header labels: Full Name, Login, Permission, Source and Manager
+func loadCSVGrants(f string) ([]reviewGrant, error) {
+	rows, col, err := readCSVColumns(f)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range []string{"full name", "login", "permission"} {
+		if _, exists := col[c]; !exists {
+			return nil, errors.New(fmt.Sprintf("CSV has no '%s' column, use a report with -layout long", c))
+		}
+	}
+
+	var grants []reviewGrant
+	for _, row := range rows[1:] {
+		// Repos without any collaborators have no grant to review
+		if len(csvValue(row, col, "login")) == 0 {
+			continue
+		}
+		grants = append(grants, reviewGrant{
+			FullName:   csvValue(row, col, "full name"),
+			Login:      csvValue(row, col, "login"),
+			Permission: csvValue(row, col, "permission"),
+			Source:     csvValue(row, col, "source"),
+			Manager:    csvValue(row, col, "manager"),
+		})
+	}
+
+	return grants, nil
+}
+
+// readCSVColumns reads a CSV with a header row and returns its rows and the index of each
+// column keyed by its lower case header
+func readCSVColumns(f string) ([][]string, map[string]int, error) {
+	fi, err := os.Open(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fi.Close()
+
+	rows, err := csv.NewReader(fi).ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, errors.New("CSV is empty")
+	}
+	col := make(map[string]int)
+	for k, v := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(v))] = k
+	}
+
+	return rows, col, nil
+}
+
+// csvValue returns the trimmed value of the column with header c, or an empty string if
+// the CSV has no such column
+func csvValue(row []string, col map[string]int, c string) string {
+	k, exists := col[c]
+	if !exists || k >= len(row) {
+		return ""
+	}
+
+	return strings.TrimSpace(row[k])
+}
+
+// assignReviewers sets the reviewer and review ID of each grant. Reviewing by owner assigns
+// all the grants of a repo to its first admin by login
+func assignReviewers(grants []reviewGrant, by string) {
+	owners := make(map[string]string)
+	for _, v := range grants {
+		key := strings.ToLower(v.FullName)
+		if v.Permission == "admin" && (len(owners[key]) == 0 || strings.ToLower(v.Login) < strings.ToLower(owners[key])) {
+			owners[key] = v.Login
+		}
+	}
+
+	for k := range grants {
+		v := &grants[k]
+		v.ID = v.FullName + "/" + v.Login + "/" + v.Permission
+		if by == reviewByOwner {
+			v.Reviewer = owners[strings.ToLower(v.FullName)]
+		} else {
+			v.Reviewer = v.Manager
+		}
+		if len(v.Reviewer) == 0 {
+			v.Reviewer = unassignedReviewer
+		}
+	}
+}
+
+// writeReviewFiles writes a review CSV for each reviewer to a directory, returning the
+// number of files written
+func writeReviewFiles(dir string, grants []reviewGrant) (int, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return 0, err
+	}
+
+	// Group the grants by reviewer
+	byReviewer := make(map[string][]reviewGrant)
+	for _, v := range grants {
+		byReviewer[v.Reviewer] = append(byReviewer[v.Reviewer], v)
+	}
+
+	for r, list := range byReviewer {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		var rows [][]string
+		for _, v := range list {
+			rows = append(rows, []string{v.ID, v.Reviewer, v.FullName, v.Login, v.Permission, v.Source, "", ""})
+		}
+		err = writeCSVRows(filepath.Join(dir, "review-"+unsafeName.ReplaceAllString(r, "_")+".csv"), reviewHeader, rows)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(byReviewer), nil
+}
+
+// loadReviews reads the completed review files, expanding directories to the review
+// files in them. Decisions other than keep, revoke or empty are an error
+func loadReviews(paths []string) ([]reviewGrant, error) {
+	var files []string
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		found, err := filepath.Glob(filepath.Join(p, "review-*.csv"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("No review files found")
+	}
+
+	var grants []reviewGrant
+	for _, f := range files {
+		rows, col, err := readCSVColumns(f)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Problem reading %s was: %v", f, err))
+		}
+		for _, c := range reviewHeader {
+			if _, exists := col[strings.ToLower(c)]; !exists {
+				return nil, errors.New(fmt.Sprintf("%s has no '%s' column", f, c))
+			}
+		}
+		for k, row := range rows[1:] {
+			v := reviewGrant{
+				ID:         csvValue(row, col, "review id"),
+				Reviewer:   csvValue(row, col, "reviewer"),
+				FullName:   csvValue(row, col, "full name"),
+				Login:      csvValue(row, col, "login"),
+				Permission: csvValue(row, col, "permission"),
+				Source:     csvValue(row, col, "source"),
+				Decision:   strings.ToLower(csvValue(row, col, "decision")),
+				Comment:    csvValue(row, col, "comment"),
+				File:       filepath.Base(f),
+			}
+			switch v.Decision {
+			case decisionKeep, decisionRevoke:
+			case "":
+				v.Decision = decisionPending
+			default:
+				return nil, errors.New(fmt.Sprintf("%s line %d has decision '%s', use keep or revoke", f, k+2, v.Decision))
+			}
+			grants = append(grants, v)
+		}
+	}
+
+	sort.SliceStable(grants, func(i, j int) bool { return grants[i].ID < grants[j].ID })
+
+	return grants, nil
+}
+
+// countDecisions returns the number of grants to keep, to revoke and still pending
+func countDecisions(grants []reviewGrant) (int, int, int) {
+	var kept, revoked, pending int
+	for _, v := range grants {
+		switch v.Decision {
+		case decisionKeep:
+			kept++
+		case decisionRevoke:
+			revoked++
+		default:
+			pending++
+		}
+	}
+
+	return kept, revoked, pending
+}
+
+// writeAttestation writes the attestation record of every reviewed grant and the list of
+// grants to revoke to a directory
+func writeAttestation(dir string, grants []reviewGrant) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	attested := time.Now().UTC().Format(time.RFC3339)
+
+	var record, revoke [][]string
+	for _, v := range grants {
+		record = append(record, []string{v.ID, v.Reviewer, v.FullName, v.Login, v.Permission, v.Source, v.Decision, v.Comment, v.File, attested})
+		if v.Decision == decisionRevoke {
+			org, repo := splitFullName(v.FullName)
+			revoke = append(revoke, []string{org, repo, v.Login, v.Permission, v.Source, v.Reviewer, v.Comment})
+		}
+	}
+
+	err = writeCSVRows(filepath.Join(dir, "attestation.csv"),
+		[]string{"Review ID", "Reviewer", "Full Name", "Login", "Permission", "Source", "Decision", "Comment", "Review File", "Attested At"},
+		record)
+	if err != nil {
+		return err
+	}
+
+	return writeCSVRows(filepath.Join(dir, "revoke.csv"),
+		[]string{"Org", "Repo", "Login", "Permission", "Source", "Reviewer", "Comment"},
+		revoke)
+}
+
+// splitFullName splits a repo full name e.g. my-org/my-repo into the org and repo name
+func splitFullName(n string) (string, string) {
+	parts := strings.SplitN(n, "/", 2)
+	if len(parts) < 2 {
+		return "", n
+	}
+
+	return parts[0], parts[1]
+}
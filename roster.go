@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Findings when reconciling accounts with access against the roster. Accounts which aren't
+// found are unmatched rather than missing when the roster has people it only knows by email
+// and no email is known for the account, as they may well be on it
+const (
+	rosterMissing    = "not-on-roster"
+	rosterTerminated = "terminated"
+	rosterUnmatched  = "unmatched-no-email"
+)
+
+// Roster statuses which mean the person has left
+var terminatedStatuses = []string{"terminated", "inactive", "separated", "former", "left"}
+
+// Header names accepted for each roster column, compared in lower case
+var rosterHeaders = map[string][]string{
+	"login":   {"login", "github", "github login", "github_login", "github username", "username"},
+	"email":   {"email", "e-mail", "work email", "email address"},
+	"status":  {"status", "employee status", "employment status"},
+	"manager": {"manager", "manager email", "manager name"},
+}
+
+// Struct to hold a single person from the roster
+type rosterEntry struct {
+	Login   string
+	Email   string
+	Status  string
+	Manager string
+}
+
+// Struct to hold an employee roster, indexed by lower case Github login and email.
+// EmailOnly counts the people without a Github login, who can only be matched by email
+type roster struct {
+	ByLogin   map[string]rosterEntry
+	ByEmail   map[string]rosterEntry
+	EmailOnly int
+}
+
+// Struct to hold an account with access which isn't on the roster or has left
+type ghRosterException struct {
+	Org     string `json:"org"`
+	Login   string `json:"login"`
+	Access  string `json:"access"`
+	Finding string `json:"finding"`
+	Status  string `json:"status,omitempty"`
+	Manager string `json:"manager,omitempty"`
+}
+
+// loadRoster reads an employee roster CSV with a header row. It needs a login or email column
+// plus a status column, the manager column is optional. Logins match every account while
+// emails only match accounts with a known email: admins with a profile, verified domain or
+// commit email and, with -sso, members with a linked SAML identity
+func loadRoster(f string) (*roster, error) {
+	fi, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	rows, err := csv.NewReader(fi).ReadAll()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Problem reading roster %s was: %v", f, err))
+	}
+	if len(rows) == 0 {
+		return nil, errors.New(fmt.Sprintf("Roster %s is empty", f))
+	}
+
+	// Find the columns from the header row
+	cols := map[string]int{"login": -1, "email": -1, "status": -1, "manager": -1}
+	for k, h := range rows[0] {
+		h = strings.ToLower(strings.TrimSpace(h))
+		for c, names := range rosterHeaders {
+			if containsString(names, h) && cols[c] < 0 {
+				cols[c] = k
+			}
+		}
+	}
+	if cols["login"] < 0 && cols["email"] < 0 {
+		return nil, errors.New(fmt.Sprintf("Roster %s needs a login or email column", f))
+	}
+	if cols["status"] < 0 {
+		return nil, errors.New(fmt.Sprintf("Roster %s needs a status column", f))
+	}
+
+	r := roster{ByLogin: make(map[string]rosterEntry), ByEmail: make(map[string]rosterEntry)}
+	for _, row := range rows[1:] {
+		e := rosterEntry{
+			Login:   rosterValue(row, cols["login"]),
+			Email:   rosterValue(row, cols["email"]),
+			Status:  rosterValue(row, cols["status"]),
+			Manager: rosterValue(row, cols["manager"]),
+		}
+		if len(e.Login) > 0 {
+			r.ByLogin[strings.ToLower(e.Login)] = e
+		}
+		if len(e.Email) > 0 {
+			r.ByEmail[strings.ToLower(e.Email)] = e
+			if len(e.Login) == 0 {
+				r.EmailOnly++
+			}
+		}
+	}
+
+	return &r, nil
+}
+
+// rosterValue returns the trimmed value of column k of a roster row, or an empty string
+// if the column doesn't exist
+func rosterValue(row []string, k int) string {
+	if k < 0 || k >= len(row) {
+		return ""
+	}
+
+	return strings.TrimSpace(row[k])
+}
+
+// lookup finds a Github account on the roster by its login then by any of the emails known
+// for the account
+func (r *roster) lookup(l string, emails ...string) (rosterEntry, bool) {
+	e, exists := r.ByLogin[strings.ToLower(l)]
+	if exists {
+		return e, true
+	}
+	for _, v := range emails {
+		if len(v) == 0 {
+			continue
+		}
+		e, exists = r.ByEmail[strings.ToLower(v)]
+		if exists {
+			return e, true
+		}
+	}
+
+	return rosterEntry{}, false
+}
+
+// lookupAccount finds a Github account of an org on the roster using the emails from the
+// account's profile and SAML SSO identity
+func (r *roster) lookupAccount(d *ghOrgData, l string) (rosterEntry, bool) {
+	return r.lookup(l, accountEmails(d, l)...)
+}
+
+// accountEmails returns the emails known for a Github account of an org from its profile
+// and SAML SSO identity, which are only looked up for admins and with -sso
+func accountEmails(d *ghOrgData, l string) []string {
+	n := d.Names[l]
+	id := d.SSO[strings.ToLower(l)]
+	var emails []string
+	for _, v := range []string{n.Email, n.SSOEmail, n.SSONameID, id.Email, id.NameID} {
+		if len(v) > 0 {
+			emails = append(emails, v)
+		}
+	}
+
+	return emails
+}
+
+// rosterManager returns the manager of a Github account of an org from the roster, or an
+// empty string if there's no roster or the account isn't on it
+func rosterManager(d *ghOrgData, l string) string {
+	if d.Roster == nil {
+		return ""
+	}
+	e, _ := d.Roster.lookupAccount(d, l)
+
+	return e.Manager
+}
+
+// terminated returns true if the roster status means the person has left
+func (e rosterEntry) terminated() bool {
+	return containsString(terminatedStatuses, strings.ToLower(e.Status))
+}
+
+// collectRoster takes a pointer to ghAPIClient and ghOrgData, gathers the members of the org and
+// adds the roster status and manager of each admin to the name lookup map
+func collectRoster(g *ghAPIClient, d *ghOrgData) error {
+	d.Roster = g.Roster
+
+	// Only organizations have members, which may already have been got for SSO identities
+	if g.OwnerType == ownerOrg && d.Members == nil {
+		members := ghMembers{}
+		err := getMembers(g, "", &members)
+		if err != nil {
+			return err
+		}
+		for _, v := range members {
+			d.Members = append(d.Members, v.Login)
+		}
+	}
+
+	for l, v := range d.Names {
+		e, exists := d.Roster.lookupAccount(d, l)
+		if !exists {
+			continue
+		}
+		v.Manager = e.Manager
+		v.RosterStatus = e.Status
+		d.Names[l] = v
+	}
+
+	return nil
+}
+
+// rosterExceptions takes the data collected for one or more orgs and returns the accounts with
+// access, as an org member or repo collaborator, that aren't on the roster or have left
+func rosterExceptions(orgs []ghOrgData) []ghRosterException {
+	var found []ghRosterException
+	for k := range orgs {
+		o := &orgs[k]
+		if o.Roster == nil {
+			continue
+		}
+
+		// Gather every account with access and the repos they can access
+		logins := make(map[string]string)
+		repos := make(map[string][]string)
+		admin := make(map[string]int)
+		for _, l := range o.Members {
+			logins[strings.ToLower(l)] = l
+		}
+		for _, r := range o.Repos {
+			for _, c := range o.Collabs[r.Name] {
+				if isBot(c.Login, c.Type) {
+					continue
+				}
+				key := strings.ToLower(c.Login)
+				logins[key] = c.Login
+				repos[key] = append(repos[key], r.Name)
+				if c.Permissions.Admin {
+					admin[key]++
+				}
+			}
+		}
+
+		for key, l := range logins {
+			var access []string
+			if containsString(o.Members, l) {
+				access = append(access, "member")
+			}
+			if admin[key] > 0 {
+				access = append(access, "admin of "+strconv.Itoa(admin[key])+" repos")
+			}
+			if len(repos[key]) > 0 {
+				access = append(access, "collaborator on "+strconv.Itoa(len(repos[key]))+" repos")
+			}
+
+			e, exists := o.Roster.lookupAccount(o, l)
+			x := ghRosterException{Org: o.Org, Login: l, Access: strings.Join(access, "; ")}
+			switch {
+			case !exists && o.Roster.EmailOnly > 0 && len(accountEmails(o, l)) == 0:
+				x.Finding = rosterUnmatched
+			case !exists:
+				x.Finding = rosterMissing
+			case e.terminated():
+				x.Finding = rosterTerminated
+				x.Status = e.Status
+				x.Manager = e.Manager
+			default:
+				continue
+			}
+			found = append(found, x)
+		}
+	}
+
+	// Terminated accounts first as they're the most urgent, unmatched ones last
+	order := map[string]int{rosterTerminated: 0, rosterMissing: 1, rosterUnmatched: 2}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Finding != found[j].Finding {
+			return order[found[i].Finding] < order[found[j].Finding]
+		}
+		if found[i].Org != found[j].Org {
+			return found[i].Org < found[j].Org
+		}
+		return strings.ToLower(found[i].Login) < strings.ToLower(found[j].Login)
+	})
+
+	return found
+}
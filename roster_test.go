@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testRoster writes a roster CSV and loads it
+func testRoster(t *testing.T, content string) (*roster, error) {
+	f := filepath.Join(t.TempDir(), "roster.csv")
+	err := ioutil.WriteFile(f, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loadRoster(f)
+}
+
+func TestLoadRoster(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		logins    int
+		emails    int
+		emailOnly int
+		wantErr   bool
+	}{
+		{
+			name:    "logins and emails",
+			content: "GitHub Username,Work Email,Employment Status,Manager\njdoe,jane@example.com,Active,boss@example.com\n,al@example.com,Active,\n",
+			logins:  1, emails: 2, emailOnly: 1,
+		},
+		{
+			name:    "logins only",
+			content: "login,status\njdoe,active\nasmith,terminated\n",
+			logins:  2,
+		},
+		{
+			name:    "emails only",
+			content: "email,status\njane@example.com,active\n",
+			emails:  1, emailOnly: 1,
+		},
+		{name: "empty", content: "", wantErr: true},
+		{name: "no login or email", content: "name,status\nJane,active\n", wantErr: true},
+		{name: "no status", content: "login,manager\njdoe,boss\n", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := testRoster(t, tc.content)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("loadRoster returned error %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if len(r.ByLogin) != tc.logins || len(r.ByEmail) != tc.emails || r.EmailOnly != tc.emailOnly {
+				t.Errorf("loadRoster found %d logins, %d emails and %d email only, want %d, %d and %d",
+					len(r.ByLogin), len(r.ByEmail), r.EmailOnly, tc.logins, tc.emails, tc.emailOnly)
+			}
+		})
+	}
+}
+
+func TestRosterExceptions(t *testing.T) {
+	// jdoe is an admin with a profile email, asmith a member with a linked SSO identity,
+	// left a collaborator who has left and nomail and stranger members with no email known
+	org := func(t *testing.T, content string) ghOrgData {
+		r, err := testRoster(t, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := testOrgData(t, "acme", `[{"name":"api"}]`, map[string]string{
+			"api": `[{"login":"jdoe","permissions":{"admin":true}},{"login":"left"},{"login":"dependabot[bot]","type":"Bot"}]`,
+		})
+		d.Roster = r
+		d.Members = []string{"jdoe", "asmith", "nomail", "stranger"}
+		d.Names = map[string]ghNameDetail{"jdoe": {Email: "jane@example.com"}}
+		d.SSO = map[string]ghSSOIdentity{"asmith": {NameID: "al@example.com"}}
+		return d
+	}
+
+	tests := []struct {
+		name   string
+		roster string
+		want   []ghRosterException
+	}{
+		{
+			name:   "roster keyed by email",
+			roster: "email,status,manager\njane@example.com,active,boss\nal@example.com,active,boss\nleft@example.com,terminated,boss\nnomail@example.com,active,boss\n",
+			want: []ghRosterException{
+				{Org: "acme", Login: "left", Access: "collaborator on 1 repos", Finding: rosterUnmatched},
+				{Org: "acme", Login: "nomail", Access: "member", Finding: rosterUnmatched},
+				{Org: "acme", Login: "stranger", Access: "member", Finding: rosterUnmatched},
+			},
+		},
+		{
+			name:   "roster keyed by login",
+			roster: "login,status,manager\njdoe,active,boss\nasmith,active,boss\nleft,terminated,boss\nnomail,active,boss\n",
+			want: []ghRosterException{
+				{Org: "acme", Login: "left", Access: "collaborator on 1 repos", Finding: rosterTerminated, Status: "terminated", Manager: "boss"},
+				{Org: "acme", Login: "stranger", Access: "member", Finding: rosterMissing},
+			},
+		},
+		{
+			name:   "roster with logins and emails",
+			roster: "login,email,status\n,jane@example.com,active\n,al@example.com,inactive\nleft,,active\n",
+			want: []ghRosterException{
+				{Org: "acme", Login: "asmith", Access: "member", Finding: rosterTerminated, Status: "inactive"},
+				{Org: "acme", Login: "nomail", Access: "member", Finding: rosterUnmatched},
+				{Org: "acme", Login: "stranger", Access: "member", Finding: rosterUnmatched},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := rosterExceptions([]ghOrgData{org(t, tc.roster)})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("rosterExceptions =\n%+v\nwant\n%+v", got, tc.want)
+			}
+		})
+	}
+}
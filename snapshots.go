@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout used to name snapshot files, sortable and safe in file names
+const snapshotLayout = "2006-01-02T150405Z"
+
+// Metrics that can be charted over time from the snapshot store
+var snapshotMetrics = map[string]string{
+	"repos":        "Number of repos",
+	"public":       "Number of public repos",
+	"stale":        "Number of repos not pushed to in stale-days",
+	"admin-grants": "Total admin grants across all repos",
+	"avg-admins":   "Average number of admins per repo",
+	"no-admins":    "Number of repos without an admin",
+}
+
+// Struct to hold a snapshot file and the report read from it
+type storedSnapshot struct {
+	File   string
+	Taken  time.Time
+	Report jsonReport
+}
+
+// writeSnapshot takes a directory, the data collected for one or more orgs and whether orphaned
+// repos were asked for and adds a JSON snapshot of the run to the directory, named by the UTC
+// time of the run
+func writeSnapshot(dir string, orgs []ghOrgData, orphans bool) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	f := filepath.Join(dir, time.Now().UTC().Format(snapshotLayout)+".json")
+
+	return f, writeJSON(f, orgs, orphans)
+}
+
+// snapshotsCommand handles the snapshots command which lists the snapshot store or charts
+// metrics over time from it, exiting when done
+func snapshotsCommand(args []string) {
+	fs := newFlagSet("snapshots")
+	var dir, metric, repo, format string
+	var staleDays int
+	fs.StringVar(&dir, "dir", "snapshots", "Provide the directory holding the snapshots")
+	fs.StringVar(&metric, "metric", "public", "Provide the metric to chart over time, one of:\n"+metricList())
+	fs.StringVar(&repo, "repo", "", "Provide a repo full name e.g. my-org/my-repo to chart its admin count")
+	fs.StringVar(&format, "format", "text", "Provide the output format, either text or csv")
+	fs.IntVar(&staleDays, "stale-days", 365, "Repos not pushed to in this many days are stale")
+	action := parseAction(fs, args)
+
+	snaps, err := loadSnapshots(dir)
+	if err != nil {
+		fmt.Printf("Error reading snapshots from %s was %+v\n", dir, err)
+		os.Exit(exitError)
+	}
+
+	switch action {
+	case "list":
+		listSnapshots(os.Stdout, snaps)
+	case "trend":
+		if _, exists := snapshotMetrics[metric]; !exists && len(repo) == 0 {
+			fmt.Printf("ERROR: Unknown metric '%s'\n", metric)
+			fs.Usage()
+			os.Exit(exitError)
+		}
+		if format != "text" && format != formatCSV {
+			fmt.Printf("ERROR: Unsupported trend format '%s', use text or csv\n", format)
+			os.Exit(exitError)
+		}
+		label, points := snapshotTrend(snaps, metric, repo, staleDays)
+		if format == formatCSV {
+			err = writeTrendCSV(os.Stdout, label, points)
+		} else {
+			err = writeTrendChart(os.Stdout, label, points)
+		}
+		if err != nil {
+			fmt.Printf("Error writing trend was %+v\n", err)
+			os.Exit(exitError)
+		}
+	default:
+		fmt.Printf("Error: Unknown snapshots command '%s'\n", action)
+		fs.Usage()
+		os.Exit(exitError)
+	}
+}
+
+// metricList returns the names and descriptions of the snapshot metrics, one per line
+func metricList() string {
+	var names []string
+	for k := range snapshotMetrics {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, k := range names {
+		lines = append(lines, fmt.Sprintf("  %-13s - %s", k, snapshotMetrics[k]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// loadSnapshots reads every snapshot in a directory, oldest first
+func loadSnapshots(dir string) ([]storedSnapshot, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var snaps []storedSnapshot
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		s := storedSnapshot{File: f}
+		err = json.Unmarshal(b, &s.Report)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Problem reading snapshot %s was: %v", f, err))
+		}
+		s.Taken = s.Report.GeneratedAt
+		snaps = append(snaps, s)
+	}
+	sort.SliceStable(snaps, func(i, j int) bool { return snaps[i].Taken.Before(snaps[j].Taken) })
+
+	return snaps, nil
+}
+
+// listSnapshots writes a line for each snapshot with its time, orgs and repo count
+func listSnapshots(w io.Writer, snaps []storedSnapshot) {
+	if len(snaps) == 0 {
+		fmt.Fprintln(w, "No snapshots found")
+		return
+	}
+	for _, s := range snaps {
+		var orgs []string
+		repos := 0
+		for _, o := range s.Report.Orgs {
+			orgs = append(orgs, o.Org)
+			repos += len(o.Repos)
+		}
+		fmt.Fprintf(w, "%s  %5d repos  %s  (%s)\n", s.Taken.Format(time.RFC3339), repos, strings.Join(orgs, ", "), filepath.Base(s.File))
+	}
+}
+
+// Struct to hold a single value of a metric at a point in time
+type trendPoint struct {
+	Taken time.Time
+	Value float64
+}
+
+// snapshotTrend returns a label for the metric and its value in each snapshot. When repo is
+// set the metric is the number of admins of that repo
+func snapshotTrend(snaps []storedSnapshot, metric string, repo string, staleDays int) (string, []trendPoint) {
+	label := snapshotMetrics[metric]
+	if len(repo) > 0 {
+		label = "Admins of " + repo
+	} else if metric == "stale" {
+		label = fmt.Sprintf("Number of repos not pushed to in %d days", staleDays)
+	}
+
+	var points []trendPoint
+	for _, s := range snaps {
+		var repos, public, stale, grants, noAdmins int
+		repoAdmins := -1
+		cutoff := s.Taken.AddDate(0, 0, -staleDays)
+		for _, o := range s.Report.Orgs {
+			for _, r := range o.Repos {
+				repos++
+				if strings.EqualFold(r.Visibility, "public") {
+					public++
+				}
+				if r.PushedAt.Before(cutoff) {
+					stale++
+				}
+				grants += len(r.Admins)
+				if len(r.Admins) == 0 {
+					noAdmins++
+				}
+				if strings.EqualFold(r.FullName, repo) {
+					repoAdmins = len(r.Admins)
+				}
+			}
+		}
+
+		p := trendPoint{Taken: s.Taken}
+		switch {
+		case len(repo) > 0:
+			// Skip snapshots where the repo didn't exist
+			if repoAdmins < 0 {
+				continue
+			}
+			p.Value = float64(repoAdmins)
+		case metric == "repos":
+			p.Value = float64(repos)
+		case metric == "public":
+			p.Value = float64(public)
+		case metric == "stale":
+			p.Value = float64(stale)
+		case metric == "admin-grants":
+			p.Value = float64(grants)
+		case metric == "avg-admins":
+			if repos > 0 {
+				p.Value = float64(grants) / float64(repos)
+			}
+		case metric == "no-admins":
+			p.Value = float64(noAdmins)
+		}
+		points = append(points, p)
+	}
+
+	return label, points
+}
+
+// writeTrendChart writes a metric over time as a text bar chart
+func writeTrendChart(w io.Writer, label string, points []trendPoint) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, label)
+	if len(points) == 0 {
+		fmt.Fprintln(b, "  No data")
+		return b.Flush()
+	}
+
+	// Scale the bars to the largest value
+	max := 0.0
+	for _, p := range points {
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	for _, p := range points {
+		bar := 0
+		if max > 0 {
+			bar = int(p.Value / max * 50)
+		}
+		fmt.Fprintf(b, "  %s  %8s  %s\n", p.Taken.Format("2006-01-02 15:04"), formatValue(p.Value), strings.Repeat("#", bar))
+	}
+
+	return b.Flush()
+}
+
+// writeTrendCSV writes a metric over time as a two column CSV
+func writeTrendCSV(w io.Writer, label string, points []trendPoint) error {
+	csvFile := csv.NewWriter(w)
+	err := csvFile.Write([]string{"Snapshot", label})
+	if err != nil {
+		return err
+	}
+	for _, p := range points {
+		err = csvFile.Write([]string{p.Taken.Format(time.RFC3339), formatValue(p.Value)})
+		if err != nil {
+			return err
+		}
+	}
+	csvFile.Flush()
+
+	return csvFile.Error()
+}
+
+// formatValue formats a metric value, dropping the decimals for whole numbers
+func formatValue(v float64) string {
+	if v == float64(int64(v)) {
+		return strconv.FormatInt(int64(v), 10)
+	}
+
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTrendCSV(t *testing.T) {
+	points := []trendPoint{
+		{Taken: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), Value: 3},
+		{Taken: time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), Value: 2.5},
+	}
+
+	// Labels with commas or quotes are quoted rather than splitting the column
+	var b strings.Builder
+	err := writeTrendCSV(&b, `Admins of "acme/api", including bots`, points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Snapshot,"Admins of ""acme/api"", including bots"` + "\n" +
+		"2022-06-01T00:00:00Z,3\n" +
+		"2022-07-01T00:00:00Z,2.50\n"
+	if b.String() != want {
+		t.Errorf("writeTrendCSV wrote\n%s\nwant\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Schema for the SQLite export, dates are stored as RFC3339 text
+const sqliteSchema = `
+CREATE TABLE orgs (
+	org                                   TEXT PRIMARY KEY,
+	name                                  TEXT,
+	type                                  TEXT,
+	created_at                            TEXT,
+	public_repos                          INTEGER,
+	total_private_repos                   INTEGER,
+	default_repository_permission         TEXT,
+	two_factor_requirement_enabled        INTEGER,
+	members_can_create_repositories       INTEGER,
+	members_can_create_public_repositories INTEGER,
+	members_can_fork_private_repositories INTEGER,
+	web_commit_signoff_required           INTEGER,
+	is_verified                           INTEGER,
+	plan                                  TEXT,
+	filled_seats                          INTEGER,
+	seats                                 INTEGER
+);
+CREATE TABLE repos (
+	id             INTEGER PRIMARY KEY,
+	org            TEXT NOT NULL REFERENCES orgs(org),
+	name           TEXT NOT NULL,
+	full_name      TEXT NOT NULL UNIQUE,
+	description    TEXT,
+	html_url       TEXT,
+	private        INTEGER,
+	fork           INTEGER,
+	archived       INTEGER,
+	disabled       INTEGER,
+	is_template    INTEGER,
+	visibility     TEXT,
+	language       TEXT,
+	license        TEXT,
+	default_branch TEXT,
+	size           INTEGER,
+	stargazers     INTEGER,
+	forks          INTEGER,
+	open_issues    INTEGER,
+	created_at     TEXT,
+	updated_at     TEXT,
+	pushed_at      TEXT
+);
+CREATE TABLE users (
+	login        TEXT PRIMARY KEY,
+	type         TEXT,
+	site_admin   INTEGER,
+	name         TEXT,
+	email        TEXT,
+	email_source TEXT,
+	sso_status   TEXT,
+	sso_name_id  TEXT,
+	sso_email    TEXT
+);
+CREATE TABLE collaborators (
+	repo_id   INTEGER NOT NULL REFERENCES repos(id),
+	login     TEXT NOT NULL REFERENCES users(login),
+	role_name TEXT,
+	admin     INTEGER,
+	maintain  INTEGER,
+	push      INTEGER,
+	triage    INTEGER,
+	pull      INTEGER,
+	PRIMARY KEY (repo_id, login)
+);
+CREATE TABLE invitations (
+	id         INTEGER,
+	org        TEXT NOT NULL REFERENCES orgs(org),
+	level      TEXT,
+	repo       TEXT,
+	invitee    TEXT,
+	inviter    TEXT,
+	role       TEXT,
+	created_at TEXT,
+	age_days   INTEGER,
+	stale      INTEGER
+);
+CREATE INDEX collaborators_login ON collaborators(login);
+CREATE VIEW repo_admins AS
+	SELECT r.org, r.full_name, c.login, u.name, u.email
+	FROM collaborators c
+	JOIN repos r ON r.id = c.repo_id
+	LEFT JOIN users u ON u.login = c.login
+	WHERE c.role_name = 'admin';
+`
+
+// writeSQLite takes a file name and the data collected for one or more orgs and writes a
+// normalized SQLite database with tables for orgs, repos, users, collaborators and invitations.
+// Any existing file with the same name is replaced
+func writeSQLite(f string, orgs []ghOrgData) error {
+	// Start with a fresh database
+	err := os.Remove(f)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := sql.Open("sqlite3", f)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		return err
+	}
+
+	// Load everything in a single transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, o := range orgs {
+		err = insertOrg(tx, o)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertOrg adds the org, its repos, collaborators, users and invitations to the database
+func insertOrg(tx *sql.Tx, o ghOrgData) error {
+	i := o.Info
+	// An org can also be reported as a user account e.g. -org acme -user acme
+	_, err := tx.Exec(`INSERT OR IGNORE INTO orgs VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		o.Org, i.Name, i.Type, sqlTime(i.CreatedAt), i.PublicRepos, i.TotalPrivateRepos,
+		i.DefaultRepositoryPermission, i.TwoFactorRequirementEnabled, i.MembersCanCreateRepositories,
+		i.MembersCanCreatePublicRepositories, i.MembersCanForkPrivateRepositories,
+		i.WebCommitSignoffRequired, i.IsVerified, i.Plan.Name, i.Plan.FilledSeats, i.Plan.Seats)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range o.Repos {
+		// The same repo can be reported twice e.g. with -org acme -user acme, as
+		// /users/{login}/repos also lists the repos of an org with that login
+		_, err = tx.Exec(`INSERT OR IGNORE INTO repos VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			r.ID, o.Org, r.Name, r.FullName, r.Description, r.HTMLURL, r.Private, r.Fork, r.Archived,
+			r.Disabled, r.IsTemplate, r.Visibility, r.Language, r.License.SpdxID, r.DefaultBranch,
+			r.Size, r.StargazersCount, r.ForksCount, r.OpenIssuesCount, sqlTime(r.CreatedAt),
+			sqlTime(r.UpdatedAt), sqlTime(r.PushedAt))
+		if err != nil {
+			return err
+		}
+
+		for _, c := range o.Collabs[r.Name] {
+			// Users show up across repos and orgs so only add them once
+			_, err = tx.Exec(`INSERT OR IGNORE INTO users (login, type, site_admin) VALUES (?, ?, ?)`,
+				c.Login, c.Type, c.SiteAdmin)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(`INSERT OR REPLACE INTO collaborators VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				r.ID, c.Login, c.RoleName, c.Permissions.Admin, c.Permissions.Maintain,
+				c.Permissions.Push, c.Permissions.Triage, c.Permissions.Pull)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Add the details looked up for admins, keeping details found while reporting on another org
+	for l, d := range o.Names {
+		_, err = tx.Exec(`UPDATE users SET name = COALESCE(?, name), email = COALESCE(?, email),
+			email_source = COALESCE(?, email_source), sso_status = COALESCE(?, sso_status),
+			sso_name_id = COALESCE(?, sso_name_id), sso_email = COALESCE(?, sso_email) WHERE login = ?`, sqlText(d.Name), sqlText(d.Email), sqlText(d.EmailSource), sqlText(d.SSOStatus),
+			sqlText(d.SSONameID), sqlText(d.SSOEmail), l)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, v := range o.Invitations {
+		_, err = tx.Exec(`INSERT INTO invitations VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			v.ID, o.Org, v.Level, sqlText(v.Repo), v.Invitee, v.Inviter, v.Role, sqlTime(v.CreatedAt),
+			v.AgeDays, v.Stale)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sqlTime returns a time as RFC3339 text or NULL for an unset time
+func sqlTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// sqlText returns a string or NULL for an empty string
+func sqlText(s string) interface{} {
+	if len(s) == 0 {
+		return nil
+	}
+
+	return s
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Struct to hold the description of a ghorg2csv command used to generate its usage
+type cliCommand struct {
+	Name string
+	// Short summary shown in the list of commands
+	Summary string
+	// Ways to run the command shown after ghorg2csv and the command name
+	// e.g. [flags] OLD-REPORT NEW-REPORT
+	Synopsis []string
+	// Longer description shown above the command's flags, one line per entry
+	About []string
+}
+
+// The commands of ghorg2csv in the order they are listed in the usage
+var commands = []cliCommand{
+	{
+		Name:     "repos",
+		Summary:  "Report on the repos of orgs, an enterprise or a user and their admins",
+		Synopsis: []string{"-org ORG[,ORG...] [flags]", "-enterprise SLUG [flags]", "-user LOGIN|@me [flags]"},
+		About: []string{
+			"Writes a report with a row per repo listing its admins, plus a CSV named like",
+			"org-info-cross-org-admins.csv of users who are admins in more than one org.",
+			"For formats other than csv, the report is written to the -csv name with the",
+			"format as the extension e.g. .json in place of .csv",
+			"",
+			"Example:",
+			"      $ ghorg2csv repos -csv \"org-info.csv\" -org \"my-github-org\"",
+			"      $ ghorg2csv repos -csv \"all-orgs.csv\" -enterprise \"my-enterprise\"",
+			"      $ ghorg2csv repos -csv \"my-repos.csv\" -user \"@me\"",
+			"      $ ghorg2csv repos -csv \"org-info.csv\" -org \"my-github-org\" \\",
+			"          -columns \"full_name,topics,license.spdx_id:License,pushed_at,admins\"",
+			"      $ ghorg2csv repos -config \"ghorg2csv.yaml\" -format json",
+		},
+	},
+	{
+		Name:     "members",
+		Summary:  "List the members of orgs with their role",
+		Synopsis: []string{"-org ORG[,ORG...] [flags]", "-enterprise SLUG [flags]"},
+		About: []string{
+			"Writes a row per org member with their role, admin for org owners or member,",
+			"optionally with the org's outside collaborators and each user's name and email.",
+			"",
+			"Example:",
+			"      $ ghorg2csv members -csv \"members.csv\" -org \"my-github-org\" -outside",
+		},
+	},
+	{
+		Name:     "teams",
+		Summary:  "List the teams of orgs with their members and repo permissions",
+		Synopsis: []string{"-org ORG[,ORG...] [flags]", "-enterprise SLUG [flags]"},
+		About: []string{
+			"Writes a row per team and repo with the team's permission on the repo and its",
+			"members. Teams without any repos get a single row with an empty repo.",
+			"",
+			"Example:",
+			"      $ ghorg2csv teams -csv \"teams.csv\" -org \"my-github-org\"",
+		},
+	},
+	{
+		Name:     "audit",
+		Summary:  "Check repos against a policy and look for orphaned repos and excess access",
+		Synopsis: []string{"-org ORG[,ORG...] [flags]", "-enterprise SLUG [flags]", "-user LOGIN|@me [flags]"},
+		About: []string{
+			"Writes the same report as repos, then checks it against a -policy file and",
+			"writes the findings next to the report e.g. org-info-violations.csv.",
+			"With -check the exit code is 0 when there are no violations, 1 when",
+			"violations are found and 2 if ghorg2csv fails to run.",
+			"",
+			"Example:",
+			"      $ ghorg2csv audit -csv \"org-info.csv\" -org \"my-github-org\" \\",
+			"          -policy \"policy.yaml\" -check -sarif \"results.sarif\"",
+		},
+	},
+	{
+		Name:     "diff",
+		Summary:  "Compare two previous reports",
+		Synopsis: []string{"[flags] OLD-REPORT NEW-REPORT"},
+		About: []string{
+			"Compares two previous reports and lists added and removed repos,",
+			"visibility changes, archived changes and admins added or removed.",
+			"Reports can be the csv (wide or long layout), json or ndjson output.",
+			"json reports are the most reliable as their admins are structured data.",
+			"Archived changes need the archived column in csv reports and csv reports",
+			"written with custom header labels need the same -columns to be provided.",
+			"",
+			"Example:",
+			"      $ ghorg2csv diff last-week.json this-week.json",
+		},
+	},
+	{
+		Name:     "snapshots",
+		Summary:  "List snapshots or chart a metric over time",
+		Synopsis: []string{"list [-dir DIR]", "trend [-dir DIR] [-metric METRIC | -repo ORG/REPO] [flags]"},
+		About: []string{
+			"Snapshots are added to the store by running repos or audit with -snapshots DIR",
+		},
+	},
+	{
+		Name:     "review",
+		Summary:  "Split a report into access review files and ingest the decisions",
+		Synopsis: []string{"split [-by manager|owner] [-out DIR] REPORT", "ingest [-out DIR] REVIEW-FILE|DIR ..."},
+		About: []string{
+			"split writes a review CSV per reviewer listing every grant they need to",
+			"review with an empty Decision column to fill in with keep or revoke.",
+			"REPORT is a csv report with -layout long, which has every collaborator,",
+			"or a json report, which only has the admins. Run the report with -roster",
+			"to review by manager.",
+			"",
+			"ingest reads the completed review files and writes attestation.csv, a",
+			"record of every decision, and revoke.csv, the grants to remove.",
+		},
+	},
+	{
+		Name:     "remediate",
+		Summary:  "Plan and apply access removals, downgrades and archiving on Github",
+		Synopsis: []string{"plan [flags]", "apply [-plan FILE] [-log FILE]"},
+		About: []string{
+			"plan is a dry run which writes the changes to make to a plan CSV without",
+			"changing anything. Rows can be deleted from the plan before applying it.",
+			"apply makes the changes in the plan through the Github API and logs every",
+			"change, successful or not, as a line of JSON. Each change is checked against",
+			"Github first and skipped if no longer needed e.g. the user is no longer a",
+			"direct collaborator, the invitation was accepted or the repo was pushed to",
+			"since the plan was written. Needs GHTOKEN to be set",
+		},
+	},
+}
+
+// findCommand returns the command with the name provided and true, or false if there's
+// no such command
+func findCommand(n string) (cliCommand, bool) {
+	for _, c := range commands {
+		if c.Name == n {
+			return c, true
+		}
+	}
+
+	return cliCommand{}, false
+}
+
+// printUsage prints the usage help output for this program, generated from the commands
+func printUsage() {
+	fmt.Println("")
+	fmt.Println("Usage of ghorg2csv")
+	fmt.Println("")
+	fmt.Println("  ghorg2csv COMMAND [flags]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	for _, c := range commands {
+		fmt.Printf("  %-10s  %s\n", c.Name, c.Summary)
+	}
+	fmt.Printf("  %-10s  %s\n", "help", "Print the flags of a command e.g. ghorg2csv help repos")
+	fmt.Printf("  %-10s  %s\n", "version", "Print the version and exit")
+	fmt.Println("")
+	fmt.Println("  Run 'ghorg2csv COMMAND -help' for the flags of a command.")
+	fmt.Println("  Note: GNU-style arguments like --name are also supported")
+	fmt.Println("")
+	fmt.Println("  Flags not provided on the command line are read from environmental")
+	fmt.Println("  variables named like " + envName("api-url") + " for -api-url, then from")
+	fmt.Println("  the YAML file provided with -config or " + envName("config") + ".")
+	fmt.Println("")
+	fmt.Println("  WARNING: The token used to authenticate with the Github API must")
+	fmt.Println("  be passed as an environmental variable named 'GHTOKEN', unless")
+	fmt.Println("  another method is chosen with -auth")
+	fmt.Println("")
+}
+
+// newFlagSet takes the name of a command and returns a flag set for its arguments which
+// prints usage help generated from the command and its flags
+func newFlagSet(n string) *flag.FlagSet {
+	fs := flag.NewFlagSet(n, flag.ExitOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		printCommandUsage(n, fs)
+	}
+
+	return fs
+}
+
+// printCommandUsage prints the usage help output for a command from its description
+// and the flags defined in its flag set
+func printCommandUsage(n string, fs *flag.FlagSet) {
+	c, _ := findCommand(n)
+	fmt.Println("")
+	fmt.Printf("Usage of ghorg2csv %s\n", n)
+	fmt.Println("")
+	for _, v := range c.Synopsis {
+		fmt.Printf("  ghorg2csv %s %s\n", n, v)
+	}
+	if len(c.About) > 0 {
+		fmt.Println("")
+		for _, v := range c.About {
+			fmt.Println(strings.TrimRight("  "+v, " "))
+		}
+	}
+	fmt.Println("")
+	fmt.Println("Flags:")
+	fs.PrintDefaults()
+	fmt.Println("")
+}
+
+// parseAction takes the flag set of a command which is followed by an action e.g. snapshots
+// list and the command's arguments, then parses the flags after the action and returns the
+// action. The usage is printed and the program exits if no action was provided
+func parseAction(fs *flag.FlagSet, args []string) string {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		// Parsing here handles -help before the action
+		fs.Parse(args)
+		fmt.Printf("Error: ghorg2csv %s needs an action\n", fs.Name())
+		fs.Usage()
+		os.Exit(exitError)
+	}
+	fs.Parse(args[1:])
+
+	return args[0]
+}
@@ -1,81 +1,174 @@
 package main
 
 import (
-	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"path"
 	"strings"
 )
 
-// handleStandardArgs takes 4 booleans: h and help for help argument plus
-// v and version for version arguement and produces the correct output -
-// either the help message or the programs version
-func handleStarndarArgs(h bool, help bool, v bool, version bool) {
-	// Print help for errors with command-line arguments
-	if len(os.Args) == 1 || // No arguments
-		len(flag.Args()) > 0 { // Unsupported or extra arguments
-		help = true
-		fmt.Println("Error: Incorrect command-line arguments proivded, printing help...")
+// Ensure required arguments are provided by checking that csv name is at least 5 characters
+// and ends in '.csv' as well as ensuring that one of org, enterprise or user isn't empty (the default value)
+func requiredArgs(c string, o string, e string, u string) {
+	// Simple length check of CSV file
+	if len(c) < 5 {
+		fmt.Println("ERROR: CSV name is too short, smallest possible length is 5 characters e.g. a.csv")
+		os.Exit(exitError)
 	}
+	// Ensure csv name ends in .csv
+	if !strings.HasSuffix(c, ".csv") {
+		fmt.Println("ERROR: CSV name should end in '.csv' e.g. my-GH-Org.csv")
+		os.Exit(exitError)
+	}
+
+	// Make sure there's a Github org argument provided
+	if len(splitOrgs(o)) == 0 && len(e) == 0 && len(strings.TrimSpace(u)) == 0 {
+		fmt.Println("Please provide a Github org with the -org argument, an enterprise with -enterprise or a user with -user")
+		os.Exit(exitError)
+	}
+}
 
-	// Print help when h/help argument given
-	if help || h {
-		printHelp()
-		os.Exit(0)
+// Ensure the output format argument is one of the supported formats
+func formatArgs(f string) {
+	if !validFormat(f) {
+		fmt.Printf("ERROR: Unsupported output format '%s', use csv, json, ndjson, xlsx, html or sqlite\n", f)
+		os.Exit(exitError)
 	}
+}
 
-	// Check for version command-line argument
-	if version || v {
-		fmt.Printf("ghorg2csv version %s\n", ver)
-		os.Exit(0)
+// Ensure the columns argument only names known columns, returning the parsed columns
+func columnArgs(c string) []csvColumn {
+	cols, err := parseColumns(c)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(exitError)
 	}
 
-	return
+	return cols
 }
 
-// printHelp prints the usage help output for this program
-func printHelp() {
-	fmt.Println("")
-	fmt.Println("Usage of ghorg2csv")
-	fmt.Println("")
-	fmt.Println("  -csv  string")
-	fmt.Println("        REQUIRED - Provide the name of the CSV to create")
-	fmt.Println("  -org  string")
-	fmt.Println("        REQUIRED - Provide the name of the Github organization")
-	fmt.Println("  -help, -h")
-	fmt.Println("        Print this help message and exit")
-	fmt.Println("  -version, -v")
-	fmt.Println("        Print the version and exit, ignoring all other arguments")
-	fmt.Println("")
-	fmt.Println("  Note: GNU-style arguments like --name are also supported")
-	fmt.Println("")
-	fmt.Println("  WARNING: The token used to authenticate with the Github API must")
-	fmt.Println("  be passed as an environmental variable named 'GHTOKEN'")
-	fmt.Println("")
-	fmt.Println("  Example:")
-	fmt.Println("        $ ghorg2csv  --csv \"org-info.csv\" --org \"my-github-org\"")
-	fmt.Println("")
+// Ensure the layout argument is one of the supported CSV layouts
+func layoutArgs(l string) {
+	if l != layoutWide && l != layoutLong {
+		fmt.Printf("ERROR: Unsupported layout '%s', use wide or long\n", l)
+		os.Exit(exitError)
+	}
+}
 
+// Ensure the policy argument names a readable policy file with valid rules, returning the
+// loaded policy or nil if no policy file was provided
+func policyArgs(p string) *policy {
+	if len(p) == 0 {
+		return nil
+	}
+	rules, err := loadPolicy(p)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	return rules
 }
 
-// Ensure required arguments are provided by checking that csv name is at least 5 characters
-// and ends in '.csv' as well as ensuring that org isn't empty (the default value)
-func requiredArgs(c string, o string) {
-	// Simple length check of CSV file
-	if len(c) < 5 {
-		fmt.Println("ERROR: CSV name is too short, smallest possible length is 5 characters e.g. a.csv")
-		os.Exit(1)
+// Ensure a policy was provided when checking or writing JUnit XML or SARIF
+func checkArgs(p *policy, check bool, junit string, sarif string) {
+	if p == nil && (check || len(junit) > 0 || len(sarif) > 0) {
+		fmt.Println("ERROR: -check, -junit and -sarif need a policy file provided with -policy")
+		os.Exit(exitError)
 	}
-	// Ensure csv name ends in .csv
-	if !strings.HasSuffix(c, ".csv") {
-		fmt.Println("ERROR: CSV name should end in '.csv' e.g. my-GH-Org.csv")
-		os.Exit(1)
+}
+
+// Ensure the roster argument names a readable employee roster, returning the loaded roster
+// or nil if no roster was provided
+func rosterArgs(r string) *roster {
+	if len(r) == 0 {
+		return nil
+	}
+	employees, err := loadRoster(r)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(exitError)
 	}
 
-	// Make sure there's a Github org argument provided
-	if len(o) == 0 {
-		fmt.Println("Please provide a Github org with the -org argument")
-		printHelp()
-		os.Exit(1)
+	return employees
+}
+
+// Ensure a policy was provided for tracking issues and the central issues repo is an org/repo
+func issuesArgs(p *policy, issues bool, repo string, apply bool) {
+	if p == nil && (issues || len(repo) > 0 || apply) {
+		fmt.Println("ERROR: -issues, -issues-repo and -issues-apply need a policy file provided with -policy")
+		os.Exit(exitError)
+	}
+	if len(repo) > 0 && len(strings.Split(repo, "/")) != 2 {
+		fmt.Printf("ERROR: -issues-repo '%s' should be in the form org/repo\n", repo)
+		os.Exit(exitError)
 	}
 }
+
+// Ensure emailing the report has a sender and recipients, returning where to deliver the report
+func deliveryArgs(host string, from string, to string, user string, webhook string) delivery {
+	d := delivery{SMTPHost: host, SMTPFrom: from, SMTPUser: user, Webhook: webhook}
+	for _, v := range strings.Split(to, ",") {
+		if len(strings.TrimSpace(v)) > 0 {
+			d.SMTPTo = append(d.SMTPTo, strings.TrimSpace(v))
+		}
+	}
+	if len(host) > 0 && (len(from) == 0 || len(d.SMTPTo) == 0) {
+		fmt.Println("ERROR: -smtp-host needs -smtp-from and -smtp-to")
+		os.Exit(exitError)
+	}
+	if len(host) > 0 && !strings.Contains(host, ":") {
+		fmt.Printf("ERROR: -smtp-host '%s' should be in the form host:port\n", host)
+		os.Exit(exitError)
+	}
+
+	return d
+}
+
+// Ensure the Github API URL is an http(s) URL and the auth method is supported with what it needs
+func connectionArgs(c ghConnection) {
+	u, err := url.Parse(c.APIURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		fmt.Printf("ERROR: -api-url '%s' should be a URL e.g. https://github.example.com/api/v3\n", c.APIURL)
+		os.Exit(exitError)
+	}
+	if !containsString(authMethods, c.Auth) {
+		fmt.Printf("ERROR: Unsupported auth method '%s', use %s\n", c.Auth, strings.Join(authMethods, ", "))
+		os.Exit(exitError)
+	}
+	if c.Auth == authTokenFile && len(c.TokenFile) == 0 {
+		fmt.Println("ERROR: -auth token-file needs the file provided with -token-file")
+		os.Exit(exitError)
+	}
+}
+
+// Ensure at least one repo is collected at a time
+func concurrencyArgs(n int) {
+	if n < 1 {
+		fmt.Println("ERROR: -concurrency should be at least 1")
+		os.Exit(exitError)
+	}
+}
+
+// Ensure the filter arguments have known visibilities and valid patterns, returning the filter
+func filterArgs(vis string, archived bool, forks bool, include string, exclude string) repoFilter {
+	f := repoFilter{ExcludeArchived: archived, ExcludeForks: forks}
+	for _, v := range splitOrgs(strings.ToLower(vis)) {
+		if v != "public" && v != "private" && v != "internal" {
+			fmt.Printf("ERROR: Unsupported visibility '%s', use public, private or internal\n", v)
+			os.Exit(exitError)
+		}
+		f.Visibility = append(f.Visibility, v)
+	}
+	f.Include = splitOrgs(strings.ToLower(include))
+	f.Exclude = splitOrgs(strings.ToLower(exclude))
+	for _, v := range append(f.Include, f.Exclude...) {
+		if _, err := path.Match(v, ""); err != nil {
+			fmt.Printf("ERROR: Invalid repo name pattern '%s'\n", v)
+			os.Exit(exitError)
+		}
+	}
+
+	return f
+}
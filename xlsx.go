@@ -0,0 +1,421 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Kinds of value an XLSX cell can hold
+const (
+	xlsxString = iota
+	xlsxNumber
+	xlsxBool
+	xlsxDate
+)
+
+// Style indexes from xlsxStyles, header cells are bold and dates use a date number format
+const (
+	xlsxStyleDefault = 0
+	xlsxStyleHeader  = 1
+	xlsxStyleDate    = 2
+)
+
+// Struct to hold a single cell of an XLSX worksheet
+type xlsxCell struct {
+	Kind int
+	Str  string
+	Num  float64
+	Bool bool
+	Date time.Time
+}
+
+// Struct to hold a single worksheet, the header row is frozen and has an autofilter
+type xlsxSheet struct {
+	Name   string
+	Header []string
+	Rows   [][]xlsxCell
+}
+
+// Helpers to create cells of each kind
+func xlsxStr(s string) xlsxCell     { return xlsxCell{Kind: xlsxString, Str: s} }
+func xlsxInt(n int) xlsxCell        { return xlsxCell{Kind: xlsxNumber, Num: float64(n)} }
+func xlsxBoolean(b bool) xlsxCell   { return xlsxCell{Kind: xlsxBool, Bool: b} }
+func xlsxTime(t time.Time) xlsxCell { return xlsxCell{Kind: xlsxDate, Date: t} }
+
+// writeXLSX takes a file name and the data collected for one or more orgs and writes an
+// Excel workbook with a sheet each for a summary, repos, repo admins and org settings
+func writeXLSX(f string, orgs []ghOrgData) error {
+	sheets := []xlsxSheet{
+		xlsxSummarySheet(orgs),
+		xlsxReposSheet(orgs),
+		xlsxAdminsSheet(orgs),
+		xlsxOrgSheet(orgs),
+	}
+
+	// Create the XLSX file
+	fi, err := os.Create(f)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	return writeWorkbook(fi, sheets)
+}
+
+// xlsxSummarySheet returns a sheet with repo counts for each org plus a total row
+func xlsxSummarySheet(orgs []ghOrgData) xlsxSheet {
+	s := xlsxSheet{
+		Name: "Summary",
+		Header: []string{"Org", "Repos", "Public", "Private", "Internal", "Forks",
+			"Archived", "Repos Without Admins", "Distinct Admins"},
+	}
+
+	// Count the repos in each org
+	var total [8]int
+	allAdmins := make(map[string]bool)
+	for _, o := range orgs {
+		var c [8]int
+		admins := make(map[string]bool)
+		for _, r := range o.Repos {
+			c[0]++
+			switch r.Visibility {
+			case "public":
+				c[1]++
+			case "internal":
+				c[3]++
+			default:
+				c[2]++
+			}
+			if r.Fork {
+				c[4]++
+			}
+			if r.Archived {
+				c[5]++
+			}
+			if len(o.Admins[r.Name]) == 0 {
+				c[6]++
+			}
+			for _, a := range o.Admins[r.Name] {
+				admins[a.Login] = true
+				allAdmins[a.Login] = true
+			}
+		}
+		c[7] = len(admins)
+
+		row := []xlsxCell{xlsxStr(o.Org)}
+		for k := range c {
+			row = append(row, xlsxInt(c[k]))
+			total[k] += c[k]
+		}
+		s.Rows = append(s.Rows, row)
+	}
+
+	// Add a total row when there's more than one org
+	if len(orgs) > 1 {
+		total[7] = len(allAdmins)
+		row := []xlsxCell{xlsxStr("All Orgs")}
+		for k := range total {
+			row = append(row, xlsxInt(total[k]))
+		}
+		s.Rows = append(s.Rows, row)
+	}
+
+	return s
+}
+
+// xlsxReposSheet returns a sheet with a row for each repo
+func xlsxReposSheet(orgs []ghOrgData) xlsxSheet {
+	s := xlsxSheet{
+		Name: "Repos",
+		Header: []string{"Org", "Full Name", "Name", "Description", "Private", "Fork", "Archived",
+			"Visibility", "Language", "Default Branch", "Created", "Last Update", "Last Push", "Admin Count"},
+	}
+	for _, o := range orgs {
+		for _, r := range o.Repos {
+			s.Rows = append(s.Rows, []xlsxCell{
+				xlsxStr(o.Org),
+				xlsxStr(r.FullName),
+				xlsxStr(r.Name),
+				xlsxStr(r.Description),
+				xlsxBoolean(r.Private),
+				xlsxBoolean(r.Fork),
+				xlsxBoolean(r.Archived),
+				xlsxStr(r.Visibility),
+				xlsxStr(r.Language),
+				xlsxStr(r.DefaultBranch),
+				xlsxTime(r.CreatedAt),
+				xlsxTime(r.UpdatedAt),
+				xlsxTime(r.PushedAt),
+				xlsxInt(len(o.Admins[r.Name])),
+			})
+		}
+	}
+
+	return s
+}
+
+// xlsxAdminsSheet returns a sheet with a row for each admin of each repo
+func xlsxAdminsSheet(orgs []ghOrgData) xlsxSheet {
+	s := xlsxSheet{
+		Name:   "Repo Admins",
+		Header: []string{"Org", "Repo", "Login", "Name", "Email", "Email Source", "Permission", "SSO Identity"},
+	}
+	for _, o := range orgs {
+		for _, r := range o.Repos {
+			for _, a := range o.Admins[r.Name] {
+				d := o.Names[a.Login]
+				s.Rows = append(s.Rows, []xlsxCell{
+					xlsxStr(o.Org),
+					xlsxStr(r.FullName),
+					xlsxStr(a.Login),
+					xlsxStr(d.Name),
+					xlsxStr(d.Email),
+					xlsxStr(d.EmailSource),
+					xlsxStr(a.RoleName),
+					xlsxStr(labelSSO(d)),
+				})
+			}
+		}
+	}
+
+	return s
+}
+
+// xlsxOrgSheet returns a sheet with a row of settings for each org
+func xlsxOrgSheet(orgs []ghOrgData) xlsxSheet {
+	s := xlsxSheet{
+		Name: "Org Settings",
+		Header: []string{"Org", "Name", "Type", "Created", "Public Repos", "Private Repos",
+			"Default Repo Permission", "2FA Required", "Members Can Create Repos",
+			"Members Can Create Public Repos", "Members Can Fork Private Repos",
+			"Web Commit Signoff Required", "Verified", "Plan", "Filled Seats", "Seats"},
+	}
+	for _, o := range orgs {
+		i := o.Info
+		s.Rows = append(s.Rows, []xlsxCell{
+			xlsxStr(o.Org),
+			xlsxStr(i.Name),
+			xlsxStr(i.Type),
+			xlsxTime(i.CreatedAt),
+			xlsxInt(i.PublicRepos),
+			xlsxInt(i.TotalPrivateRepos),
+			xlsxStr(i.DefaultRepositoryPermission),
+			xlsxBoolean(i.TwoFactorRequirementEnabled),
+			xlsxBoolean(i.MembersCanCreateRepositories),
+			xlsxBoolean(i.MembersCanCreatePublicRepositories),
+			xlsxBoolean(i.MembersCanForkPrivateRepositories),
+			xlsxBoolean(i.WebCommitSignoffRequired),
+			xlsxBoolean(i.IsVerified),
+			xlsxStr(i.Plan.Name),
+			xlsxInt(i.Plan.FilledSeats),
+			xlsxInt(i.Plan.Seats),
+		})
+	}
+
+	return s
+}
+
+// writeWorkbook takes an io.Writer and the worksheets to include and writes them out
+// as the zipped XML parts that make up an XLSX workbook
+// see http://officeopenxml.com/anatomyofOOXML-xlsx.php
+func writeWorkbook(w io.Writer, sheets []xlsxSheet) error {
+	z := zip.NewWriter(w)
+
+	// Gather all the parts of the workbook
+	parts := []struct {
+		name string
+		body []byte
+	}{
+		{"[Content_Types].xml", xlsxContentTypes(sheets)},
+		{"_rels/.rels", []byte(xlsxRootRels)},
+		{"xl/workbook.xml", xlsxWorkbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels(sheets)},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+	}
+	for k := range sheets {
+		parts = append(parts, struct {
+			name string
+			body []byte
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", k+1), xlsxWorksheet(sheets[k])})
+	}
+
+	// Write each part to the zip
+	for _, p := range parts {
+		pw, err := z.Create(p.name)
+		if err != nil {
+			return err
+		}
+		_, err = pw.Write(p.body)
+		if err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
+
+const xlsxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const xlsxRootRels = xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// Styles with a bold font for the header row and a date format for date cells
+const xlsxStyles = xlsxHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// xlsxContentTypes returns the content types part listing each part of the workbook
+func xlsxContentTypes(sheets []xlsxSheet) []byte {
+	var b bytes.Buffer
+	b.WriteString(xlsxHeader)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for k := range sheets {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, k+1)
+	}
+	b.WriteString(`</Types>`)
+
+	return b.Bytes()
+}
+
+// xlsxWorkbook returns the workbook part listing each sheet plus the hidden
+// defined names Excel expects for each sheet's autofilter
+func xlsxWorkbook(sheets []xlsxSheet) []byte {
+	var b bytes.Buffer
+	b.WriteString(xlsxHeader)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	b.WriteString(`<sheets>`)
+	for k, s := range sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(s.Name), k+1, k+1)
+	}
+	b.WriteString(`</sheets><definedNames>`)
+	for k, s := range sheets {
+		fmt.Fprintf(&b, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">'%s'!%s</definedName>`,
+			k, xmlEscape(s.Name), xlsxAbsRange(s))
+	}
+	b.WriteString(`</definedNames></workbook>`)
+
+	return b.Bytes()
+}
+
+// xlsxWorkbookRels returns the relationships from the workbook to its sheets and styles
+func xlsxWorkbookRels(sheets []xlsxSheet) []byte {
+	var b bytes.Buffer
+	b.WriteString(xlsxHeader)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for k := range sheets {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, k+1, k+1)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+	b.WriteString(`</Relationships>`)
+
+	return b.Bytes()
+}
+
+// xlsxWorksheet returns the XML for a single worksheet with a frozen header row and an autofilter
+func xlsxWorksheet(s xlsxSheet) []byte {
+	var b bytes.Buffer
+	b.WriteString(xlsxHeader)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0">`)
+	b.WriteString(`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	b.WriteString(`<selection pane="bottomLeft" activeCell="A2" sqref="A2"/>`)
+	b.WriteString(`</sheetView></sheetViews>`)
+	b.WriteString(`<sheetData>`)
+
+	// Header row
+	b.WriteString(`<row r="1">`)
+	for k, h := range s.Header {
+		fmt.Fprintf(&b, `<c r="%s1" t="inlineStr" s="%d"><is><t>%s</t></is></c>`, xlsxColumn(k), xlsxStyleHeader, xmlEscape(h))
+	}
+	b.WriteString(`</row>`)
+
+	// Data rows
+	for r, row := range s.Rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+2)
+		for k, c := range row {
+			ref := xlsxColumn(k) + strconv.Itoa(r+2)
+			switch c.Kind {
+			case xlsxNumber:
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(c.Num, 'f', -1, 64))
+			case xlsxBool:
+				v := 0
+				if c.Bool {
+					v = 1
+				}
+				fmt.Fprintf(&b, `<c r="%s" t="b"><v>%d</v></c>`, ref, v)
+			case xlsxDate:
+				// Leave unset dates empty rather than showing 1899
+				if c.Date.IsZero() {
+					continue
+				}
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleDate, strconv.FormatFloat(xlsxSerial(c.Date), 'f', -1, 64))
+			default:
+				if len(c.Str) == 0 {
+					continue
+				}
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(c.Str))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData>`)
+	fmt.Fprintf(&b, `<autoFilter ref="%s"/>`, xlsxRange(s))
+	b.WriteString(`</worksheet>`)
+
+	return b.Bytes()
+}
+
+// xlsxColumn converts a zero based column index into an Excel column name e.g. 0 is A and 27 is AB
+func xlsxColumn(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+
+	return name
+}
+
+// xlsxRange returns the cell range covering the header and data rows of a sheet e.g. A1:H10
+func xlsxRange(s xlsxSheet) string {
+	return "A1:" + xlsxColumn(len(s.Header)-1) + strconv.Itoa(len(s.Rows)+1)
+}
+
+// xlsxAbsRange returns the same range as xlsxRange using absolute references e.g. $A$1:$H$10
+func xlsxAbsRange(s xlsxSheet) string {
+	return "$A$1:$" + xlsxColumn(len(s.Header)-1) + "$" + strconv.Itoa(len(s.Rows)+1)
+}
+
+// xlsxSerial converts a time to the serial number Excel uses for dates, the days since 1899-12-30
+func xlsxSerial(t time.Time) float64 {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return t.UTC().Sub(epoch).Hours() / 24
+}
+
+// xmlEscape returns s with the characters special to XML escaped
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,12 @@
+package main
+
+import "testing"
+
+func TestXLSXColumn(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for i, want := range tests {
+		if got := xlsxColumn(i); got != want {
+			t.Errorf("xlsxColumn(%d) = %q, want %q", i, got, want)
+		}
+	}
+}